@@ -0,0 +1,179 @@
+package qi
+
+import (
+	"context"
+	"fmt"
+)
+
+// PaymentBalance summarizes how much of a payment has been captured,
+// released, cancelled and refunded.
+type PaymentBalance struct {
+	PaymentID string
+	Currency  Currency
+	Amount    float64
+	Captured  float64
+	Released  float64 // the remainder of a partial confirmation
+	Cancelled float64
+	Refunded  float64
+}
+
+// NewPaymentBalance computes the balance of a payment from its status and the
+// refunds known to have been made against it. Only the confirmed amount is
+// captured: a payment awaiting confirmation has nothing captured, and a
+// partial confirmation releases the rest of the authorized amount.
+// Cancellations are only counted if the status carries the Cancels extension.
+func NewPaymentBalance(status *PaymentStatusResponse, refunds []Refund) *PaymentBalance {
+	var captured, released, cancelled, refunded int64
+	currency := status.Currency
+
+	if status.IsSettled() {
+		captured = AmountUnits(status.ConfirmedAmount)
+		if released = AmountUnits(status.Amount) - captured; released < 0 {
+			released = 0
+		}
+	}
+
+	for _, cancel := range status.Cancels {
+		if cancel.Successfully {
+			cancelled += AmountUnits(cancel.Amount)
+		}
+	}
+
+	for _, refund := range refunds {
+		if refund.Status == RefundStatusFailed || refund.Canceled {
+			continue
+		}
+		refunded += AmountUnits(refund.Amount)
+		for _, cancel := range refund.Cancels {
+			if cancel.Successfully {
+				refunded -= AmountUnits(cancel.Amount)
+			}
+		}
+	}
+
+	return &PaymentBalance{
+		PaymentID: status.PaymentID,
+		Currency:  currency,
+		Amount:    status.Amount,
+		Captured:  AmountFromUnits(captured),
+		Released:  AmountFromUnits(released),
+		Cancelled: AmountFromUnits(cancelled),
+		Refunded:  AmountFromUnits(refunded),
+	}
+}
+
+// Remaining returns the amount of the payment that has not been released,
+// cancelled or refunded.
+func (b *PaymentBalance) Remaining() float64 {
+	return b.nonNegative(AmountUnits(b.Amount) - AmountUnits(b.Released) - AmountUnits(b.Cancelled) - AmountUnits(b.Refunded))
+}
+
+// Refundable returns the captured amount that can still be refunded.
+func (b *PaymentBalance) Refundable() float64 {
+	return b.nonNegative(AmountUnits(b.Captured) - AmountUnits(b.Cancelled) - AmountUnits(b.Refunded))
+}
+
+func (b *PaymentBalance) nonNegative(units int64) float64 {
+	if units < 0 {
+		return 0
+	}
+	return AmountFromUnits(units)
+}
+
+// BalanceError is returned by the balance guard when a cancel or refund
+// exceeds the available balance of the payment.
+type BalanceError struct {
+	PaymentID string
+	Operation string
+	Requested float64
+	Available float64
+}
+
+// Error implements the error interface.
+func (e *BalanceError) Error() string {
+	return fmt.Sprintf("%s of %.2f exceeds available balance %.2f for payment %s",
+		e.Operation, e.Requested, e.Available, e.PaymentID)
+}
+
+// RefundSource provides the refunds made against a payment. The API has no
+// endpoint listing refunds, so they must be recorded by the caller.
+type RefundSource interface {
+	PaymentRefunds(ctx context.Context, paymentID string) ([]Refund, error)
+}
+
+// WithBalanceGuard enables checking partial cancel and refund amounts against
+// the payment balance before calling the API. The refunds source may be nil,
+// in which case only cancellations are taken into account.
+func WithBalanceGuard(refunds RefundSource) ClientOption {
+	return func(c *Client) {
+		c.balanceGuard = true
+		c.refundSource = refunds
+	}
+}
+
+// GetPaymentBalance retrieves the payment status by payment ID and computes
+// its balance.
+func (c *Client) GetPaymentBalance(ctx context.Context, paymentID string, opts ...CallOption) (*PaymentBalance, error) {
+	status, err := c.GetPaymentStatus(ctx, paymentID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.paymentBalance(ctx, status)
+}
+
+func (c *Client) paymentBalance(ctx context.Context, status *PaymentStatusResponse) (*PaymentBalance, error) {
+	var refunds []Refund
+	if c.refundSource != nil {
+		var err error
+		refunds, err = c.refundSource.PaymentRefunds(ctx, status.PaymentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get refunds: %w", err)
+		}
+	}
+	return NewPaymentBalance(status, refunds), nil
+}
+
+// checkBalance verifies a partial cancel or refund against the payment
+// balance when the balance guard is enabled. Exactly one of paymentID and
+// requestID is set. The status is looked up with the options of the call.
+func (c *Client) checkBalance(ctx context.Context, operation, paymentID, requestID string, amount float64, opts []CallOption) error {
+	if !c.balanceGuard || amount <= 0 {
+		return nil
+	}
+
+	// The idempotency key and response metadata belong to the guarded call.
+	opts = append(opts[:len(opts):len(opts)], func(o *callOptions) {
+		o.idempotencyKey = ""
+		o.meta = nil
+	})
+
+	var status *PaymentStatusResponse
+	var err error
+	if paymentID != "" {
+		status, err = c.GetPaymentStatus(ctx, paymentID, opts...)
+	} else {
+		status, err = c.GetPaymentStatusByRequest(ctx, requestID, opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	balance, err := c.paymentBalance(ctx, status)
+	if err != nil {
+		return err
+	}
+
+	available := balance.Remaining()
+	if operation == "refund" {
+		available = balance.Refundable()
+	}
+	if AmountUnits(amount) > AmountUnits(available) {
+		return &BalanceError{
+			PaymentID: status.PaymentID,
+			Operation: operation,
+			Requested: amount,
+			Available: available,
+		}
+	}
+	return nil
+}
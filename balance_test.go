@@ -0,0 +1,194 @@
+package qi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestNewPaymentBalance(t *testing.T) {
+	status := &qi.PaymentStatusResponse{
+		PaymentID:       "test-payment-id",
+		Status:          qi.PaymentStatusSuccess,
+		Amount:          100,
+		ConfirmedAmount: 90,
+		Cancels: []qi.Cancel{
+			{Successfully: true, Amount: 10},
+			{Successfully: false, Amount: 50},
+		},
+	}
+	refunds := []qi.Refund{
+		{Amount: 20, Status: qi.RefundStatusSuccess},
+		{Amount: 15, Status: qi.RefundStatusProcessing},
+		{Amount: 30, Status: qi.RefundStatusFailed},
+	}
+
+	balance := qi.NewPaymentBalance(status, refunds)
+
+	if balance.Captured != 90 {
+		t.Errorf("expected captured 90, got %v", balance.Captured)
+	}
+	if balance.Cancelled != 10 {
+		t.Errorf("expected cancelled 10, got %v", balance.Cancelled)
+	}
+	if balance.Refunded != 35 {
+		t.Errorf("expected refunded 35, got %v", balance.Refunded)
+	}
+	if balance.Refundable() != 45 {
+		t.Errorf("expected refundable 45, got %v", balance.Refundable())
+	}
+	// The partial confirmation released the other 10.
+	if balance.Released != 10 {
+		t.Errorf("expected released 10, got %v", balance.Released)
+	}
+	if balance.Remaining() != 45 {
+		t.Errorf("expected remaining 45, got %v", balance.Remaining())
+	}
+}
+
+func TestNewPaymentBalanceAwaitingConfirmation(t *testing.T) {
+	status := &qi.PaymentStatusResponse{
+		PaymentID: "test-payment-id",
+		Status:    qi.PaymentStatusSuccess,
+		Amount:    100,
+		Cancels:   []qi.Cancel{{Successfully: true, Amount: 30}},
+	}
+
+	balance := qi.NewPaymentBalance(status, nil)
+
+	if balance.Captured != 0 || balance.Released != 0 {
+		t.Errorf("expected nothing captured or released, got %+v", balance)
+	}
+	if balance.Refundable() != 0 {
+		t.Errorf("expected refundable 0, got %v", balance.Refundable())
+	}
+	if balance.Remaining() != 70 {
+		t.Errorf("expected remaining 70, got %v", balance.Remaining())
+	}
+}
+
+type refundSource []qi.Refund
+
+func (s refundSource) PaymentRefunds(ctx context.Context, paymentID string) ([]qi.Refund, error) {
+	return s, nil
+}
+
+func TestBalanceGuard(t *testing.T) {
+	var refunded bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/payment/test-payment-id/status":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(qi.PaymentStatusResponse{
+				PaymentID:       "test-payment-id",
+				Status:          qi.PaymentStatusSuccess,
+				Amount:          100,
+				ConfirmedAmount: 100,
+			})
+		case "/payment/test-payment-id/refund":
+			refunded = true
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(qi.Refund{RefundID: "test-refund-id", Status: qi.RefundStatusSuccess})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithBalanceGuard(refundSource{{Amount: 60, Status: qi.RefundStatusSuccess}}),
+	)
+
+	_, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{Amount: 50})
+	var balanceErr *qi.BalanceError
+	if !errors.As(err, &balanceErr) {
+		t.Fatalf("expected BalanceError, got %v", err)
+	}
+	if balanceErr.Available != 40 {
+		t.Errorf("expected available 40, got %v", balanceErr.Available)
+	}
+	if refunded {
+		t.Error("refund should not be sent")
+	}
+
+	if _, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{Amount: 40}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !refunded {
+		t.Error("expected refund to be sent")
+	}
+}
+
+func TestBalanceGuardAwaitingConfirmation(t *testing.T) {
+	var refunded bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payment/test-payment-id/status" {
+			refunded = true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.PaymentStatusResponse{PaymentID: "test-payment-id", Status: qi.PaymentStatusSuccess, Amount: 100})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithBalanceGuard(nil),
+	)
+
+	_, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{Amount: 10})
+	var balanceErr *qi.BalanceError
+	if !errors.As(err, &balanceErr) {
+		t.Fatalf("expected BalanceError, got %v", err)
+	}
+	if balanceErr.Available != 0 {
+		t.Errorf("expected available 0, got %v", balanceErr.Available)
+	}
+	if refunded {
+		t.Error("refund of an unconfirmed payment should not be sent")
+	}
+}
+
+func TestBalanceGuardCallOptions(t *testing.T) {
+	keys := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys[r.URL.Path] = r.Header.Get("Idempotency-Key")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/payment/test-payment-id/status" {
+			json.NewEncoder(w).Encode(qi.PaymentStatusResponse{PaymentID: "test-payment-id", Status: qi.PaymentStatusSuccess, Amount: 100, ConfirmedAmount: 100})
+			return
+		}
+		json.NewEncoder(w).Encode(qi.Refund{RefundID: "test-refund-id", Status: qi.RefundStatusSuccess})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithBalanceGuard(refundSource{}),
+	)
+
+	var meta qi.ResponseMeta
+	_, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{Amount: 40},
+		qi.WithIdempotencyKey("refund-key"),
+		qi.WithResponseMeta(&meta),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if key := keys["/payment/test-payment-id/status"]; key != "" {
+		t.Errorf("expected no idempotency key on the balance check, got %q", key)
+	}
+	if key := keys["/payment/test-payment-id/refund"]; key != "refund-key" {
+		t.Errorf("expected the idempotency key on the refund, got %q", key)
+	}
+	if meta.Attempts != 1 || !bytes.Contains(meta.Body, []byte("test-refund-id")) {
+		t.Errorf("expected the metadata of the refund, got attempts=%d body=%s", meta.Attempts, meta.Body)
+	}
+}
@@ -0,0 +1,122 @@
+package qi
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/BynxDev/qi/internal/interval"
+)
+
+// DefaultBatchConcurrency is the default number of concurrent lookups
+// performed by GetPaymentStatuses.
+const DefaultBatchConcurrency = 4
+
+// BatchStatusOptions configures GetPaymentStatuses.
+type BatchStatusOptions struct {
+	// ByRequest looks up payments by request ID instead of payment ID.
+	ByRequest bool
+	// Concurrency is the maximum number of concurrent lookups.
+	Concurrency int
+	// RateLimit is the maximum number of lookups per second. Zero means
+	// no limit.
+	RateLimit float64
+	// MaxRetries is the number of times a lookup is retried after a
+	// transport error or a retryable APIError, overriding the retry policy of
+	// the client. Zero keeps the client's policy.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled after each
+	// attempt. Zero uses DefaultRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// StatusResult is the result of a single lookup performed by
+// GetPaymentStatuses. Exactly one of Status and Err is set.
+type StatusResult struct {
+	ID     string
+	Status *PaymentStatusResponse
+	Err    error
+}
+
+// GetPaymentStatuses retrieves the status of several payments concurrently.
+// Duplicate IDs are looked up once. Results are returned in the order the IDs
+// were first seen, and a failed lookup does not affect the others.
+func (c *Client) GetPaymentStatuses(ctx context.Context, ids []string, opts *BatchStatusOptions) []StatusResult {
+	if opts == nil {
+		opts = &BatchStatusOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	seen := make(map[string]bool, len(ids))
+	var results []StatusResult
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			results = append(results, StatusResult{ID: id})
+		}
+	}
+
+	limit := interval.NewLimiter(opts.RateLimit)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *StatusResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.Status, r.Err = c.lookupStatus(ctx, r.ID, opts, limit)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+// lookupStatus looks up a single status. Retries are made by doRequest, so
+// the rate limit only spaces out the first attempts.
+func (c *Client) lookupStatus(ctx context.Context, id string, opts *BatchStatusOptions, limit *interval.Limiter) (*PaymentStatusResponse, error) {
+	if err := limit.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var callOpts []CallOption
+	if opts.MaxRetries > 0 {
+		callOpts = append(callOpts, WithRetry(RetryPolicy{MaxRetries: opts.MaxRetries, Backoff: opts.RetryBackoff}))
+	}
+	if opts.ByRequest {
+		return c.GetPaymentStatusByRequest(ctx, id, callOpts...)
+	}
+	return c.GetPaymentStatus(ctx, id, callOpts...)
+}
+
+// isRetryable returns true for transport errors and retryable API errors.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.IsRetryable()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
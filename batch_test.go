@@ -0,0 +1,114 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestGetPaymentStatuses(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/payment/"), "/status")
+
+		mu.Lock()
+		hits[id]++
+		n := hits[id]
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case id == "missing":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(qi.Error{Error: qi.ErrorDetails{
+				Code:    qi.ErrorCodePaymentNotFound,
+				Message: qi.ErrorMessagePaymentNotFound,
+			}})
+		case id == "flaky" && n == 1:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(qi.PaymentStatusResponse{PaymentID: id, Status: qi.PaymentStatusSuccess})
+		}
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	results := client.GetPaymentStatuses(context.Background(),
+		[]string{"a", "missing", "a", "flaky", "b"},
+		&qi.BatchStatusOptions{Concurrency: 2, MaxRetries: 1, RetryBackoff: time.Millisecond},
+	)
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	if hits["a"] != 1 {
+		t.Errorf("expected duplicate ID to be looked up once, got %d", hits["a"])
+	}
+
+	for _, r := range results {
+		switch r.ID {
+		case "missing":
+			apiErr, ok := r.Err.(*qi.APIError)
+			if !ok || !apiErr.IsNotFound() {
+				t.Errorf("expected not found error, got %v", r.Err)
+			}
+		default:
+			if r.Err != nil {
+				t.Errorf("%s: unexpected error: %v", r.ID, r.Err)
+			} else if r.Status.PaymentID != r.ID {
+				t.Errorf("%s: got status for %s", r.ID, r.Status.PaymentID)
+			}
+		}
+	}
+}
+
+func TestGetPaymentStatusesRetries(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/payment/"), "/status")
+		mu.Lock()
+		hits[id]++
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch id {
+		case "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "invalid":
+			w.Write([]byte(`{"paymentId":`))
+		}
+	}))
+	defer server.Close()
+
+	// The batch retries replace the client's policy instead of multiplying it.
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithRetryPolicy(qi.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}),
+	)
+	client.GetPaymentStatuses(context.Background(), []string{"down", "invalid"},
+		&qi.BatchStatusOptions{MaxRetries: 1, RetryBackoff: time.Millisecond},
+	)
+	if hits["down"] != 2 {
+		t.Errorf("expected 2 requests for a failing lookup, got %d", hits["down"])
+	}
+	// Only transport errors and retryable API errors are retried.
+	if hits["invalid"] != 1 {
+		t.Errorf("expected an undecodable response not to be retried, got %d requests", hits["invalid"])
+	}
+
+	client.GetPaymentStatuses(context.Background(), []string{"down"}, nil)
+	if hits["down"] != 6 {
+		t.Errorf("expected the client's policy without batch retries, got %d requests", hits["down"]-2)
+	}
+}
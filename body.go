@@ -0,0 +1,170 @@
+package qi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	// DefaultMaxResponseSize is the default maximum size of a response body.
+	DefaultMaxResponseSize = 10 << 20
+	// MaxErrorBodySize is the number of bytes of an error response body that
+	// are read. Longer error bodies are truncated, and reported as the raw
+	// APIError.Message since they cannot be parsed.
+	MaxErrorBodySize = 64 << 10
+)
+
+// ErrResponseTooLarge is matched by errors returned for responses larger than
+// the maximum response size.
+var ErrResponseTooLarge = errors.New("qi: response body exceeds the maximum size")
+
+// WithMaxResponseSize sets the maximum size of a response body in bytes.
+// Calls receiving a larger response fail with ErrResponseTooLarge. Zero or a
+// negative size removes the limit.
+func WithMaxResponseSize(size int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseSize = size
+	}
+}
+
+// maxPooledBuffer is the size of the largest buffers kept in bufferPool.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers that response bodies are read into when they
+// are read in full.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	// Large buffers are dropped rather than kept alive by the pool.
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// limitedReader reads at most max bytes from r, failing with
+// ErrResponseTooLarge if there are more, or all of r if max is not positive.
+// It records read errors so they can be told apart from decoding errors.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limited   bool
+	err       error
+}
+
+func newLimitedReader(r io.Reader, max int64) *limitedReader {
+	return &limitedReader{r: r, remaining: max, limited: max > 0}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.limited && int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if l.limited {
+		l.remaining -= int64(n)
+		if l.remaining < 0 {
+			l.err = ErrResponseTooLarge
+			return n + int(l.remaining), l.err
+		}
+	}
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+	return n, err
+}
+
+// decodeResponse decodes the body of a successful response into result, and
+// returns whether there was a body to decode. Bodies are read into a pooled
+// buffer, except for bodies larger than the buffers, which are decoded as
+// they are read unless the caller asked for the raw body with
+// WithResponseMeta.
+func (c *Client) decodeResponse(resp *http.Response, result interface{}, o *callOptions, meta *ResponseMeta) (bool, error) {
+	if c.maxResponseSize > 0 && resp.ContentLength > c.maxResponseSize {
+		return false, fmt.Errorf("failed to read response body: %w", ErrResponseTooLarge)
+	}
+	// The transport already stops reading at a known content length.
+	var body io.Reader = resp.Body
+	if resp.ContentLength < 0 {
+		body = newLimitedReader(resp.Body, c.maxResponseSize)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	var err error
+	if o.meta != nil || (resp.ContentLength >= 0 && resp.ContentLength <= maxPooledBuffer) {
+		_, err = buf.ReadFrom(body)
+	} else {
+		_, err = buf.ReadFrom(io.LimitReader(body, maxPooledBuffer+1))
+	}
+	if o.meta != nil {
+		meta.Body = append([]byte(nil), buf.Bytes()...)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if result == nil || buf.Len() == 0 {
+		return false, nil
+	}
+
+	if o.meta == nil && buf.Len() > maxPooledBuffer {
+		return streamResponse(io.MultiReader(bytes.NewReader(buf.Bytes()), body), result)
+	}
+	if err := json.Unmarshal(buf.Bytes(), result); err != nil {
+		return false, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return true, nil
+}
+
+// streamResponse decodes body into result as it is read.
+func streamResponse(body io.Reader, result interface{}) (bool, error) {
+	r := newLimitedReader(body, 0)
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(result); err != nil {
+		if r.err != nil {
+			return false, fmt.Errorf("failed to read response body: %w", r.err)
+		}
+		return false, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	// Like json.Unmarshal, reject anything but whitespace after the value.
+	// Reading to the end also lets the connection be reused.
+	if _, err := dec.Token(); err != io.EOF {
+		if r.err != nil {
+			return false, fmt.Errorf("failed to read response body: %w", r.err)
+		}
+		return false, errors.New("failed to unmarshal response: invalid character after top-level value")
+	}
+	return true, nil
+}
+
+// readErrorBody reads at most MaxErrorBodySize bytes of the body of an error
+// response.
+func (c *Client) readErrorBody(resp *http.Response) ([]byte, error) {
+	limit := int64(MaxErrorBodySize)
+	if c.maxResponseSize > 0 && c.maxResponseSize < limit {
+		limit = c.maxResponseSize
+	}
+	var body io.Reader = resp.Body
+	if resp.ContentLength < 0 || resp.ContentLength > limit {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	_, err := buf.ReadFrom(body)
+	return append([]byte(nil), buf.Bytes()...), err
+}
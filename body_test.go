@@ -0,0 +1,113 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	body := `{"paymentId":"test-payment-id","status":"SUCCESS","message":"` + strings.Repeat("x", 1000) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Chunked") != "" {
+			// Flushing before writing the body removes the Content-Length.
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	for _, chunked := range []string{"", "1"} {
+		header := qi.WithHeader("X-Chunked", chunked)
+		client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithMaxResponseSize(500))
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id", header); !errors.Is(err, qi.ErrResponseTooLarge) {
+			t.Errorf("chunked=%q: expected ErrResponseTooLarge, got %v", chunked, err)
+		}
+
+		client = qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithMaxResponseSize(int64(len(body))))
+		status, err := client.GetPaymentStatus(ctx, "test-payment-id", header)
+		if err != nil {
+			t.Fatalf("chunked=%q: unexpected error: %v", chunked, err)
+		}
+		if status.PaymentID != "test-payment-id" || string(status.RawJSON()) != body {
+			t.Errorf("chunked=%q: unexpected status %+v", chunked, status)
+		}
+		if _, ok := status.UnknownFields()["message"]; !ok {
+			t.Errorf("chunked=%q: expected unknown field to be captured", chunked)
+		}
+	}
+}
+
+func TestStreamedResponse(t *testing.T) {
+	// Bodies larger than the pooled buffers are decoded as they are read.
+	padding := strings.Repeat("x", 100<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.(http.Flusher).Flush()
+		w.Write([]byte(`{"paymentId":"test-payment-id","padding":"` + padding + `",`))
+		w.(http.Flusher).Flush()
+		switch r.URL.Path {
+		case "/payment/invalid/status":
+		case "/payment/trailing/status":
+			w.Write([]byte(`"status":"SUCCESS"} {"status":"FAILED"}`))
+		default:
+			w.Write([]byte(`"status":"SUCCESS"}` + "\n"))
+		}
+	}))
+	defer server.Close()
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	ctx := context.Background()
+
+	status, err := client.GetPaymentStatus(ctx, "test-payment-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.PaymentID != "test-payment-id" || status.Status != qi.PaymentStatusSuccess {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.UnknownFields()["padding"]) != len(padding)+2 {
+		t.Error("expected unknown field to be captured")
+	}
+
+	for _, id := range []string{"invalid", "trailing"} {
+		_, err = client.GetPaymentStatus(ctx, id)
+		if err == nil || !strings.HasPrefix(err.Error(), "failed to unmarshal response") {
+			t.Errorf("%s: expected unmarshal error, got %v", id, err)
+		}
+	}
+
+	client = qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithMaxResponseSize(80<<10))
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); !errors.Is(err, qi.ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestErrorBodyTruncated(t *testing.T) {
+	body := strings.Repeat("<html>gateway error</html>", qi.MaxErrorBodySize/10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	var meta qi.ResponseMeta
+	_, err := client.GetPaymentStatus(context.Background(), "test-payment-id", qi.WithResponseMeta(&meta))
+	var apiErr *qi.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadGateway || apiErr.Message != body[:qi.MaxErrorBodySize] {
+		t.Errorf("expected truncated message, got %d bytes", len(apiErr.Message))
+	}
+	if len(meta.Body) != qi.MaxErrorBodySize {
+		t.Errorf("expected truncated body, got %d bytes", len(meta.Body))
+	}
+}
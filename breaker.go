@@ -0,0 +1,259 @@
+package qi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCircuitFailureThreshold is the default number of consecutive
+	// failures that open the circuit.
+	DefaultCircuitFailureThreshold = 5
+	// DefaultCircuitOpenTimeout is the default time the circuit stays open
+	// before probe requests are let through.
+	DefaultCircuitOpenTimeout = 30 * time.Second
+)
+
+// ErrCircuitOpen is matched by errors returned for calls rejected by an open
+// circuit breaker.
+var ErrCircuitOpen = errors.New("qi: circuit breaker is open")
+
+// CircuitOpenError is returned for calls rejected by an open circuit breaker,
+// without contacting the gateway.
+type CircuitOpenError struct {
+	Operation string
+	// RetryAt is when probe requests will be let through again.
+	RetryAt time.Time
+}
+
+// Error implements the error interface.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("qi: circuit breaker is open for %s until %s", e.Operation, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) true.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all calls through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe calls through.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitEvent describes a state change of the circuit breaker of an
+// operation.
+type CircuitEvent struct {
+	Operation string
+	From      CircuitState
+	To        CircuitState
+	// Err is the failure that opened the circuit, if any.
+	Err error
+}
+
+// CircuitBreakerConfig configures WithCircuitBreaker.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive transport errors and
+	// 5xx responses that open the circuit. Zero uses
+	// DefaultCircuitFailureThreshold.
+	FailureThreshold int
+	// OpenTimeout is the time the circuit stays open before it half-opens.
+	// Zero uses DefaultCircuitOpenTimeout.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe calls let through while
+	// half-open. The circuit closes once they all succeed, and opens again
+	// if one fails. Zero uses 1.
+	HalfOpenProbes int
+	// OnStateChange is called after every state change.
+	OnStateChange func(CircuitEvent)
+}
+
+// WithCircuitBreaker enables a circuit breaker for every operation. After
+// consecutive transport errors or 5xx responses, calls to the operation fail
+// immediately with a CircuitOpenError instead of waiting for the gateway.
+func WithCircuitBreaker(config CircuitBreakerConfig) ClientOption {
+	return func(c *Client) {
+		c.breaker = newCircuitBreaker(config)
+	}
+}
+
+// CircuitState returns the state of the circuit breaker of operation. It is
+// always CircuitClosed without WithCircuitBreaker.
+func (c *Client) CircuitState(operation string) CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.state(operation)
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultCircuitOpenTimeout
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	return &circuitBreaker{config: config, circuits: make(map[string]*circuit)}
+}
+
+// circuit returns the circuit of operation, half-opening it once the open
+// timeout has passed. The caller must hold b.mu.
+func (b *circuitBreaker) circuit(operation string, events *[]CircuitEvent) *circuit {
+	c, ok := b.circuits[operation]
+	if !ok {
+		c = &circuit{}
+		b.circuits[operation] = c
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.config.OpenTimeout {
+		b.transition(operation, c, CircuitHalfOpen, nil, events)
+	}
+	return c
+}
+
+func (b *circuitBreaker) transition(operation string, c *circuit, to CircuitState, err error, events *[]CircuitEvent) {
+	*events = append(*events, CircuitEvent{Operation: operation, From: c.state, To: to, Err: err})
+	c.state = to
+	c.failures = 0
+	c.probes = 0
+	c.successes = 0
+	if to == CircuitOpen {
+		c.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) emit(events []CircuitEvent) {
+	if b.config.OnStateChange == nil {
+		return
+	}
+	for _, e := range events {
+		b.config.OnStateChange(e)
+	}
+}
+
+// allow returns a CircuitOpenError if the call must not be made.
+func (b *circuitBreaker) allow(operation string) error {
+	if b == nil {
+		return nil
+	}
+
+	var events []CircuitEvent
+	defer func() { b.emit(events) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(operation, &events)
+	switch c.state {
+	case CircuitOpen:
+		return &CircuitOpenError{Operation: operation, RetryAt: c.openedAt.Add(b.config.OpenTimeout)}
+	case CircuitHalfOpen:
+		if c.probes >= b.config.HalfOpenProbes {
+			return &CircuitOpenError{Operation: operation, RetryAt: time.Now()}
+		}
+		c.probes++
+	}
+	return nil
+}
+
+// record updates the circuit of operation with the outcome of an allowed
+// call.
+func (b *circuitBreaker) record(ctx context.Context, operation string, err error) {
+	if b == nil {
+		return
+	}
+
+	var events []CircuitEvent
+	defer func() { b.emit(events) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(operation, &events)
+	failed := isCircuitFailure(err)
+	if !failed && err != nil && ctx.Err() != nil {
+		// Canceled by the caller: neither a success nor a failure.
+		if c.state == CircuitHalfOpen && c.probes > 0 {
+			c.probes--
+		}
+		return
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.config.FailureThreshold {
+			b.transition(operation, c, CircuitOpen, err, &events)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			b.transition(operation, c, CircuitOpen, err, &events)
+			return
+		}
+		c.successes++
+		if c.successes >= b.config.HalfOpenProbes {
+			b.transition(operation, c, CircuitClosed, nil, &events)
+		}
+	}
+}
+
+func (b *circuitBreaker) state(operation string) CircuitState {
+	var events []CircuitEvent
+	defer func() { b.emit(events) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.circuit(operation, &events).state
+}
+
+// isCircuitFailure returns true for transport errors and 5xx responses.
+func isCircuitFailure(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
+}
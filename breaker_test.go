@@ -0,0 +1,112 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":24,"message":"EXTERNAL_SYSTEM_ERROR"}}`))
+			return
+		}
+		w.Write([]byte(`{"paymentId":"test-payment-id","status":"SUCCESS"}`))
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var events []qi.CircuitEvent
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithCircuitBreaker(qi.CircuitBreakerConfig{
+			FailureThreshold: 2,
+			OpenTimeout:      50 * time.Millisecond,
+			OnStateChange: func(e qi.CircuitEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, e)
+			},
+		}),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if state := client.CircuitState(qi.OperationGetPaymentStatus); state != qi.CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	_, err := client.GetPaymentStatus(ctx, "test-payment-id")
+	var openErr *qi.CircuitOpenError
+	if !errors.Is(err, qi.ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Operation != qi.OperationGetPaymentStatus {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected open circuit to fail fast, gateway hit %d times", hits.Load())
+	}
+	if state := client.CircuitState(qi.OperationCreatePayment); state != qi.CircuitClosed {
+		t.Errorf("expected other operations to be unaffected, got %s", state)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if state := client.CircuitState(qi.OperationGetPaymentStatus); state != qi.CircuitClosed {
+		t.Errorf("expected closed circuit, got %s", state)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []qi.CircuitState{qi.CircuitOpen, qi.CircuitHalfOpen, qi.CircuitClosed}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.To != want[i] || e.Operation != qi.OperationGetPaymentStatus {
+			t.Errorf("event %d: expected transition to %s, got %+v", i, want[i], e)
+		}
+	}
+	if events[0].Err == nil {
+		t.Error("expected the opening event to carry the failure")
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":12,"message":"PAYMENT_NOT_FOUND"}}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithCircuitBreaker(qi.CircuitBreakerConfig{FailureThreshold: 1}),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetPaymentStatus(context.Background(), "test-payment-id")
+		if errors.Is(err, qi.ErrCircuitOpen) {
+			t.Fatal("expected 4xx responses not to open the circuit")
+		}
+	}
+}
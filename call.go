@@ -0,0 +1,109 @@
+package qi
+
+import (
+	"net/http"
+	"time"
+)
+
+// CallOption configures a single API call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	meta           *ResponseMeta
+	timeout        time.Duration
+	header         http.Header
+	terminalID     string
+	idempotencyKey string
+	retry          RetryPolicy
+}
+
+func newCallOptions(c *Client, opts []CallOption) *callOptions {
+	o := &callOptions{
+		terminalID: c.terminalID,
+		retry:      c.retry,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCallTimeout sets a deadline for the call, including retries.
+func WithCallTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithHeader sets an additional request header for the call. It replaces
+// headers set by the client, except credentials.
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// WithTerminal overrides the terminal ID for the call.
+func WithTerminal(terminalID string) CallOption {
+	return func(o *callOptions) {
+		o.terminalID = terminalID
+	}
+}
+
+// WithIdempotencyKey sets the Idempotency-Key header for the call.
+func WithIdempotencyKey(key string) CallOption {
+	return func(o *callOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithRetry overrides the retry policy of the client for the call.
+func WithRetry(policy RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retry = policy
+	}
+}
+
+// WithResponseMeta fills meta with the details of the HTTP response when the
+// call returns, whether or not it succeeded.
+func WithResponseMeta(meta *ResponseMeta) CallOption {
+	return func(o *callOptions) {
+		o.meta = meta
+	}
+}
+
+// traceHeaders are the response headers checked by ResponseMeta.TraceID, in
+// order of preference.
+var traceHeaders = []string{"X-Request-Id", "X-Trace-Id", "X-Correlation-Id", "Traceparent"}
+
+// ResponseMeta describes the HTTP response of an API call.
+type ResponseMeta struct {
+	// StatusCode is the HTTP status code of the last attempt.
+	StatusCode int
+	// Header contains the response headers of the last attempt.
+	Header http.Header
+	// Latency is the time spent on the call, including all attempts.
+	Latency time.Duration
+	// Attempts is the number of HTTP requests made.
+	Attempts int
+	// Body is the raw response body of the last attempt, truncated to
+	// MaxErrorBodySize for error responses.
+	Body []byte
+}
+
+// TraceID returns the correlation ID returned by the gateway, if any, for
+// use in support tickets.
+func (m *ResponseMeta) TraceID() string {
+	if m == nil {
+		return ""
+	}
+	for _, key := range traceHeaders {
+		if v := m.Header.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
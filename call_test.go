@@ -0,0 +1,192 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestWithResponseMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "trace-123")
+		fmt.Fprint(w, `{"paymentId":"test-payment-id","status":"SUCCESS"}`)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	var meta qi.ResponseMeta
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id", qi.WithResponseMeta(&meta)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if meta.StatusCode != http.StatusOK {
+		t.Errorf("expected status code 200, got %d", meta.StatusCode)
+	}
+	if meta.TraceID() != "trace-123" {
+		t.Errorf("expected trace ID trace-123, got %q", meta.TraceID())
+	}
+	if meta.Attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", meta.Attempts)
+	}
+	if meta.Latency <= 0 {
+		t.Errorf("expected positive latency, got %s", meta.Latency)
+	}
+	if string(meta.Body) != `{"paymentId":"test-payment-id","status":"SUCCESS"}` {
+		t.Errorf("unexpected body %s", meta.Body)
+	}
+}
+
+func TestAPIErrorMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Trace-Id", "trace-456")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":12,"message":"PAYMENT_NOT_FOUND"}}`)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	_, err := client.GetPaymentStatus(context.Background(), "test-payment-id")
+	var apiErr *qi.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.Meta == nil || apiErr.Meta.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected response meta with status 404, got %+v", apiErr.Meta)
+	}
+	if apiErr.Meta.TraceID() != "trace-456" {
+		t.Errorf("expected trace ID trace-456, got %q", apiErr.Meta.TraceID())
+	}
+}
+
+func TestCallOptionsHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Terminal-Id"); got != "other-terminal" {
+			t.Errorf("expected X-Terminal-Id other-terminal, got %s", got)
+		}
+		if got := r.Header.Get("Idempotency-Key"); got != "key-1" {
+			t.Errorf("expected Idempotency-Key key-1, got %s", got)
+		}
+		if got := r.Header.Get("X-Custom"); got != "value" {
+			t.Errorf("expected X-Custom value, got %s", got)
+		}
+		if got := r.Header.Get("X-Signature"); got != "signature" {
+			t.Errorf("expected X-Signature to be kept, got %s", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"refundId":"test-refund-id","status":"SUCCESS"}`)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithSignature("signature"))
+
+	_, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{RequestID: "r-1"},
+		qi.WithTerminal("other-terminal"),
+		qi.WithIdempotencyKey("key-1"),
+		qi.WithHeader("X-Custom", "value"),
+		qi.WithHeader("X-Signature", "overridden"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"code":23,"message":"INTERNAL_SYSTEM_ERROR"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"paymentId":"test-payment-id","status":"SUCCESS"}`)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error without retries")
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	var meta qi.ResponseMeta
+	status, err := client.GetPaymentStatus(context.Background(), "test-payment-id",
+		qi.WithRetry(qi.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}),
+		qi.WithResponseMeta(&meta),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != qi.PaymentStatusSuccess {
+		t.Errorf("expected status SUCCESS, got %s", status.Status)
+	}
+	if meta.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", meta.Attempts)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"code":23,"message":"INTERNAL_SYSTEM_ERROR"}}`)
+	}))
+	defer server.Close()
+
+	policy := qi.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithRetryPolicy(policy))
+	unsafe := policy
+	unsafe.RetryNonIdempotent = true
+
+	tests := []struct {
+		name  string
+		req   *qi.CreateRefundRequest
+		opts  []qi.CallOption
+		calls int32
+	}{
+		{"no request ID", &qi.CreateRefundRequest{Amount: 10}, nil, 1},
+		{"request ID", &qi.CreateRefundRequest{RequestID: "f-1", Amount: 10}, nil, 3},
+		{"idempotency key", &qi.CreateRefundRequest{Amount: 10}, []qi.CallOption{qi.WithIdempotencyKey("k-1")}, 3},
+		{"opt-in", &qi.CreateRefundRequest{Amount: 10}, []qi.CallOption{qi.WithRetry(unsafe)}, 3},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+		if _, err := client.RefundPayment(context.Background(), "p-1", tt.req, tt.opts...); err == nil {
+			t.Fatalf("%s: expected error", tt.name)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: expected %d requests, got %d", tt.name, tt.calls, calls)
+		}
+	}
+}
+
+func TestWithCallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	_, err := client.GetPaymentStatus(context.Background(), "test-payment-id", qi.WithCallTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -26,6 +28,20 @@ type Client struct {
 	password   string
 	signature  string
 	httpClient *http.Client
+
+	balanceGuard bool
+	refundSource RefundSource
+	unknownHook  UnknownFunc
+	retry        RetryPolicy
+	limiter      *rateLimiter
+	breaker      *circuitBreaker
+
+	transferPaths   TransferPaths
+	confirmPaths    ConfirmPaths
+	maxResponseSize int64
+
+	tls       *tlsOptions
+	configErr error
 }
 
 // ClientOption is a function that configures a Client.
@@ -60,40 +76,155 @@ func WithSignature(signature string) ClientOption {
 	}
 }
 
+// WithRetryPolicy sets the retry policy used by every call. By default calls
+// are not retried. WithRetry overrides it for a single call.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
 // NewClient creates a new QiCard Payment Gateway API client.
 func NewClient(terminalID string, opts ...ClientOption) *Client {
 	c := &Client{
 		baseURL:    DefaultBaseURL,
 		terminalID: terminalID,
 		httpClient: &http.Client{Timeout: DefaultTimeout},
+
+		transferPaths:   DefaultTransferPaths,
+		confirmPaths:    DefaultConfirmPaths,
+		maxResponseSize: DefaultMaxResponseSize,
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.tls != nil && c.configErr == nil {
+		c.configureTLS()
+	}
 
 	return c
 }
 
-// doRequest performs an HTTP request and decodes the response.
-func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}, result interface{}) error {
-	var reqBody io.Reader
+// doRequest performs an HTTP request for operation, retrying it according to
+// the retry policy, and decodes the response.
+func (c *Client) doRequest(ctx context.Context, operation, method, path string, body interface{}, result interface{}, opts []CallOption) error {
+	if c.configErr != nil {
+		return c.configErr
+	}
+
+	o := newCallOptions(c, opts)
+	meta := &ResponseMeta{}
+	if o.meta != nil {
+		meta = o.meta
+		*meta = ResponseMeta{}
+	}
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
+	}
+
+	backoff := o.retry.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	// Calls that change a payment are only retried when the gateway can
+	// recognize the repeated request.
+	retry := method == http.MethodGet || o.retry.RetryNonIdempotent || o.idempotencyKey != "" || hasRequestID(body)
+
+	start := time.Now()
+	var decoded bool
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.breaker.allow(operation); err != nil {
+			break
+		}
+		if err = c.limiter.wait(ctx, o.terminalID, operation); err != nil {
+			c.breaker.record(ctx, operation, err)
+			break
+		}
+		decoded, err = c.doAttempt(ctx, method, path, jsonBody, result, o, meta)
+		c.breaker.record(ctx, operation, err)
+		c.limiter.observe(o.terminalID, operation, err)
+		if err == nil || !retry || attempt >= o.retry.MaxRetries || !isRetryable(ctx, err) {
+			break
+		}
+		delay := backoff << attempt
+		if _, retryAfter := isRateLimited(err); retryAfter > delay {
+			delay = retryAfter
+		}
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			break
+		}
+	}
+	meta.Latency = time.Since(start)
+	if err != nil {
+		return err
+	}
+
+	if decoded {
+		c.reportUnknown(ctx, result)
+	}
+
+	return nil
+}
+
+// hasRequestID returns true if body carries a requestId, which the gateway
+// rejects when reused instead of carrying out the call twice.
+func hasRequestID(body interface{}) bool {
+	switch b := body.(type) {
+	case *CreatePaymentRequest:
+		return b != nil && b.RequestID != ""
+	case *CancelPaymentRequest:
+		return b != nil && b.RequestID != ""
+	case *ConfirmPaymentRequest:
+		return b != nil && b.RequestID != ""
+	case *CreateRefundRequest:
+		return b != nil && b.RequestID != ""
+	case *CreateTransferRequest:
+		return b != nil && b.RequestID != ""
+	case *CancelTransferRequest:
+		return b != nil && b.RequestID != ""
+	}
+	return false
+}
+
+// doAttempt performs a single HTTP request and decodes the response body into
+// result. It returns whether a body was decoded.
+func (c *Client) doAttempt(ctx context.Context, method, path string, jsonBody []byte, result interface{}, o *callOptions, meta *ResponseMeta) (bool, error) {
+	var reqBody io.Reader
+	if jsonBody != nil {
 		reqBody = bytes.NewReader(jsonBody)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-Terminal-Id", c.terminalID)
+	req.Header.Set("X-Terminal-Id", o.terminalID)
+
+	if o.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", o.idempotencyKey)
+	}
+
+	for key, values := range o.header {
+		req.Header[key] = values
+	}
 
 	if c.username != "" && c.password != "" {
 		req.SetBasicAuth(c.username, c.password)
@@ -103,98 +234,154 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		req.Header.Set("X-Signature", c.signature)
 	}
 
+	meta.Attempts++
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return false, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	meta.StatusCode = resp.StatusCode
+	meta.Header = resp.Header
+
+	if resp.StatusCode < 400 {
+		return c.decodeResponse(resp, result, o, meta)
+	}
+
+	respBody, err := c.readErrorBody(resp)
+	meta.Body = respBody
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return false, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	if resp.StatusCode >= 400 {
-		var apiErr Error
-		if err := json.Unmarshal(respBody, &apiErr); err != nil {
-			return &APIError{
-				StatusCode: resp.StatusCode,
-				Message:    string(respBody),
-			}
-		}
-		return &APIError{
+	var apiErr Error
+	if err := json.Unmarshal(respBody, &apiErr); err != nil {
+		return false, &APIError{
 			StatusCode: resp.StatusCode,
-			Err:        &apiErr,
+			Message:    string(respBody),
+			Meta:       meta,
 		}
 	}
-
-	if result != nil && len(respBody) > 0 {
-		if err := json.Unmarshal(respBody, result); err != nil {
-			return fmt.Errorf("failed to unmarshal response: %w", err)
-		}
+	c.reportUnknownError(ctx, &apiErr)
+	return false, &APIError{
+		StatusCode: resp.StatusCode,
+		Err:        &apiErr,
+		Meta:       meta,
 	}
+}
 
-	return nil
+// escapeID escapes a payment or request ID for use as a single path segment.
+// IDs may come from untrusted input such as finish redirects, so the dot
+// segments that servers would resolve are escaped too.
+func escapeID(id string) string {
+	if id == "." || id == ".." {
+		return strings.ReplaceAll(id, ".", "%2E")
+	}
+	return url.PathEscape(id)
 }
 
 // CreatePayment creates a new payment.
-func (c *Client) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*Payment, error) {
+func (c *Client) CreatePayment(ctx context.Context, req *CreatePaymentRequest, opts ...CallOption) (*Payment, error) {
+	if req != nil {
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		req = req.normalized()
+	}
+
 	var payment Payment
-	if err := c.doRequest(ctx, http.MethodPost, "/payment", req, &payment); err != nil {
+	if err := c.doRequest(ctx, OperationCreatePayment, http.MethodPost, "/payment", req, &payment, opts); err != nil {
 		return nil, err
 	}
 	return &payment, nil
 }
 
+// PaymentFormURL returns the URL of the payment form for a payment ID.
+func (c *Client) PaymentFormURL(paymentID string) string {
+	return c.baseURL + "/payment/" + escapeID(paymentID)
+}
+
 // GetPaymentStatus retrieves the payment status by payment ID.
-func (c *Client) GetPaymentStatus(ctx context.Context, paymentID string) (*PaymentStatusResponse, error) {
+func (c *Client) GetPaymentStatus(ctx context.Context, paymentID string, opts ...CallOption) (*PaymentStatusResponse, error) {
 	var status PaymentStatusResponse
-	if err := c.doRequest(ctx, http.MethodGet, "/payment/"+paymentID+"/status", nil, &status); err != nil {
+	if err := c.doRequest(ctx, OperationGetPaymentStatus, http.MethodGet, "/payment/"+escapeID(paymentID)+"/status", nil, &status, opts); err != nil {
 		return nil, err
 	}
 	return &status, nil
 }
 
 // GetPaymentStatusByRequest retrieves the payment status by request ID.
-func (c *Client) GetPaymentStatusByRequest(ctx context.Context, requestID string) (*PaymentStatusResponse, error) {
+func (c *Client) GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (*PaymentStatusResponse, error) {
 	var status PaymentStatusResponse
-	if err := c.doRequest(ctx, http.MethodGet, "/payment/status/by/request/"+requestID, nil, &status); err != nil {
+	if err := c.doRequest(ctx, OperationGetPaymentStatusByRequest, http.MethodGet, "/payment/status/by/request/"+escapeID(requestID), nil, &status, opts); err != nil {
 		return nil, err
 	}
 	return &status, nil
 }
 
 // CancelPayment cancels a payment by payment ID.
-func (c *Client) CancelPayment(ctx context.Context, paymentID string, req *CancelPaymentRequest) (*PaymentCancelResponse, error) {
+func (c *Client) CancelPayment(ctx context.Context, paymentID string, req *CancelPaymentRequest, opts ...CallOption) (*PaymentCancelResponse, error) {
+	if req != nil {
+		if err := c.checkBalance(ctx, "cancel", paymentID, "", req.Amount, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp PaymentCancelResponse
-	if err := c.doRequest(ctx, http.MethodPost, "/payment/"+paymentID+"/cancel", req, &resp); err != nil {
+	if err := c.doRequest(ctx, OperationCancelPayment, http.MethodPost, "/payment/"+escapeID(paymentID)+"/cancel", req, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // CancelPaymentByRequest cancels a payment by request ID.
-func (c *Client) CancelPaymentByRequest(ctx context.Context, requestID string, req *CancelPaymentRequest) (*PaymentCancelResponse, error) {
+func (c *Client) CancelPaymentByRequest(ctx context.Context, requestID string, req *CancelPaymentRequest, opts ...CallOption) (*PaymentCancelResponse, error) {
+	if req != nil {
+		if err := c.checkBalance(ctx, "cancel", "", requestID, req.Amount, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp PaymentCancelResponse
-	if err := c.doRequest(ctx, http.MethodPost, "/payment/cancel/by/request/"+requestID, req, &resp); err != nil {
+	if err := c.doRequest(ctx, OperationCancelPaymentByRequest, http.MethodPost, "/payment/cancel/by/request/"+escapeID(requestID), req, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // RefundPayment creates a refund for a payment by payment ID.
-func (c *Client) RefundPayment(ctx context.Context, paymentID string, req *CreateRefundRequest) (*Refund, error) {
+func (c *Client) RefundPayment(ctx context.Context, paymentID string, req *CreateRefundRequest, opts ...CallOption) (*Refund, error) {
+	if req != nil {
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		req = req.normalized()
+		if err := c.checkBalance(ctx, "refund", paymentID, "", req.Amount, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	var refund Refund
-	if err := c.doRequest(ctx, http.MethodPost, "/payment/"+paymentID+"/refund", req, &refund); err != nil {
+	if err := c.doRequest(ctx, OperationRefundPayment, http.MethodPost, "/payment/"+escapeID(paymentID)+"/refund", req, &refund, opts); err != nil {
 		return nil, err
 	}
 	return &refund, nil
 }
 
 // RefundPaymentByRequest creates a refund for a payment by request ID.
-func (c *Client) RefundPaymentByRequest(ctx context.Context, requestID string, req *CreateRefundRequest) (*Refund, error) {
+func (c *Client) RefundPaymentByRequest(ctx context.Context, requestID string, req *CreateRefundRequest, opts ...CallOption) (*Refund, error) {
+	if req != nil {
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		req = req.normalized()
+		if err := c.checkBalance(ctx, "refund", "", requestID, req.Amount, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	var refund Refund
-	if err := c.doRequest(ctx, http.MethodPost, "/payment/refund/by/request/"+requestID, req, &refund); err != nil {
+	if err := c.doRequest(ctx, OperationRefundPaymentByRequest, http.MethodPost, "/payment/refund/by/request/"+escapeID(requestID), req, &refund, opts); err != nil {
 		return nil, err
 	}
 	return &refund, nil
@@ -1,8 +1,10 @@
 package qi_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -210,3 +212,79 @@ func TestAPIError(t *testing.T) {
 		t.Error("expected validation error")
 	}
 }
+
+// statusBody is a typical payment status response, polled while waiting for
+// the payer.
+var statusBody = []byte(`{"requestId":"test-request-id","paymentId":"test-payment-id","status":"SUCCESS",` +
+	`"amount":25000,"currency":"IQD","paymentType":"CARD","creationDate":"2024-01-15T10:30:00.000+03:00",` +
+	`"details":{"resultCode":"00","resultDescription":"Approved","rrn":"412345678901","authId":"123456",` +
+	`"authDate":"2024-01-15T10:31:12.000+03:00","maskedPan":"512345******1234","paymentSystem":"MASTER_CARD"},` +
+	`"additionalInfo":{"orderId":"order-1","customerId":"customer-1"}}`)
+
+// newBenchmarkClient returns a client whose responses are served from memory,
+// so that the benchmarks measure the client rather than the network. A
+// negative contentLength sends the body without a known length.
+func newBenchmarkClient(status int, body []byte, contentLength int64) *qi.Client {
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    status,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: contentLength,
+			Request:       r,
+		}, nil
+	})
+	return qi.NewClient("test-terminal",
+		qi.WithBaseURL("https://gateway.test"),
+		qi.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+}
+
+func BenchmarkGetPaymentStatus(b *testing.B) {
+	client := newBenchmarkClient(http.StatusOK, statusBody, int64(len(statusBody)))
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(statusBody)))
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPaymentStatusChunked(b *testing.B) {
+	client := newBenchmarkClient(http.StatusOK, statusBody, -1)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(statusBody)))
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPaymentStatusWithMeta(b *testing.B) {
+	client := newBenchmarkClient(http.StatusOK, statusBody, int64(len(statusBody)))
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(statusBody)))
+	for i := 0; i < b.N; i++ {
+		var meta qi.ResponseMeta
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id", qi.WithResponseMeta(&meta)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPaymentStatusError(b *testing.B) {
+	body := []byte(`{"error":{"code":12,"message":"PAYMENT_NOT_FOUND"}}`)
+	client := newBenchmarkClient(http.StatusNotFound, body, int64(len(body)))
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err == nil {
+			b.Fatal("expected error")
+		}
+	}
+}
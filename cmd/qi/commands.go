@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+// flagSet creates the flag set of a command. The usage line lists the
+// positional arguments.
+func (a *app) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(a.errOut, "Usage: qi %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseID parses the flags of a command taking a single payment or request ID.
+func parseID(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", errUsage
+	}
+	return fs.Arg(0), nil
+}
+
+// newRequestID returns a random UUID for use as a requestId.
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func createCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("create", "")
+	req := &qi.CreatePaymentRequest{}
+	fs.StringVar(&req.RequestID, "request-id", "", "request ID (default random UUID)")
+	fs.Float64Var(&req.Amount, "amount", 0, "payment amount")
+	currency := fs.String("currency", string(qi.CurrencyIQD), "ISO 4217 currency code")
+	fs.StringVar(&req.Locale, "locale", "", "payment form locale")
+	fs.StringVar(&req.FinishPaymentURL, "finish-url", "", "URL the payer is redirected to")
+	fs.StringVar(&req.NotificationURL, "notification-url", "", "URL notifications are sent to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	req.Currency = qi.Currency(*currency)
+	if fs.NArg() != 0 || req.Amount <= 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if req.RequestID == "" {
+		var err error
+		if req.RequestID, err = newRequestID(); err != nil {
+			return err
+		}
+	}
+
+	payment, err := a.client.CreatePayment(ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.print(payment, [][2]string{
+		{"Request ID", payment.RequestID},
+		{"Payment ID", payment.PaymentID},
+		{"Status", string(payment.Status)},
+		{"Amount", formatAmount(payment.Amount, payment.Currency)},
+		{"Created", formatTime(payment.CreationDate)},
+		{"Form URL", payment.FormURL},
+	})
+}
+
+func statusCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("status", "<payment-id>")
+	byRequest := fs.Bool("by-request", false, "treat the argument as a request ID")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var status *qi.PaymentStatusResponse
+	if *byRequest {
+		status, err = a.client.GetPaymentStatusByRequest(ctx, id)
+	} else {
+		status, err = a.client.GetPaymentStatus(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	return a.printStatus(status)
+}
+
+func cancelCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("cancel", "<payment-id>")
+	byRequest := fs.Bool("by-request", false, "treat the argument as a request ID")
+	req := &qi.CancelPaymentRequest{}
+	fs.StringVar(&req.RequestID, "request-id", "", "request ID of the cancellation (default random UUID)")
+	fs.Float64Var(&req.Amount, "amount", 0, "amount to cancel (default full amount)")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if req.RequestID == "" {
+		if req.RequestID, err = newRequestID(); err != nil {
+			return err
+		}
+	}
+
+	var resp *qi.PaymentCancelResponse
+	if *byRequest {
+		resp, err = a.client.CancelPaymentByRequest(ctx, id, req)
+	} else {
+		resp, err = a.client.CancelPayment(ctx, id, req)
+	}
+	if err != nil {
+		return err
+	}
+	return a.print(resp, [][2]string{
+		{"Request ID", resp.RequestID},
+		{"Payment ID", resp.PaymentID},
+		{"Status", string(resp.Status)},
+		{"Canceled", fmt.Sprint(resp.Canceled)},
+		{"Amount", formatAmount(resp.Amount, resp.Currency)},
+		{"Cancels", fmt.Sprint(len(resp.Cancels))},
+	})
+}
+
+func confirmCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("confirm", "<payment-id>")
+	byRequest := fs.Bool("by-request", false, "treat the argument as a request ID")
+	req := &qi.ConfirmPaymentRequest{}
+	fs.StringVar(&req.RequestID, "request-id", "", "request ID of the confirmation (default random UUID)")
+	fs.Float64Var(&req.Amount, "amount", 0, "amount to confirm (default full amount)")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if req.RequestID == "" {
+		if req.RequestID, err = newRequestID(); err != nil {
+			return err
+		}
+	}
+
+	var status *qi.PaymentStatusResponse
+	if *byRequest {
+		status, err = a.client.ConfirmPaymentByRequest(ctx, id, req)
+	} else {
+		status, err = a.client.ConfirmPayment(ctx, id, req)
+	}
+	if err != nil {
+		return err
+	}
+	return a.printStatus(status)
+}
+
+func refundCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("refund", "<payment-id>")
+	byRequest := fs.Bool("by-request", false, "treat the argument as a request ID")
+	req := &qi.CreateRefundRequest{}
+	fs.StringVar(&req.RequestID, "request-id", "", "request ID of the refund (default random UUID)")
+	fs.Float64Var(&req.Amount, "amount", 0, "amount to refund (default full amount)")
+	fs.StringVar(&req.Message, "message", "", "reason for the refund")
+	ext := &qi.RefundExtParams{}
+	fs.StringVar(&ext.Phone, "phone", "", "phone of the Instant payment recipient")
+	fs.StringVar(&ext.RecipientBankID, "recipient-bank", "", "participant ID of the Instant payment recipient's bank")
+	fs.BoolVar(&ext.ProcessRefundAsOCT, "oct", false, "process the refund as an Original Credit Transaction")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if req.RequestID == "" {
+		if req.RequestID, err = newRequestID(); err != nil {
+			return err
+		}
+	}
+	if *ext != (qi.RefundExtParams{}) {
+		req.ExtParams = ext
+	}
+
+	var refund *qi.Refund
+	if *byRequest {
+		refund, err = a.client.RefundPaymentByRequest(ctx, id, req)
+	} else {
+		refund, err = a.client.RefundPayment(ctx, id, req)
+	}
+	if err != nil {
+		return err
+	}
+	return a.print(refund, [][2]string{
+		{"Refund ID", refund.RefundID},
+		{"Request ID", refund.RequestID},
+		{"Payment ID", refund.PaymentID},
+		{"Status", string(refund.Status)},
+		{"Amount", formatAmount(refund.Amount, refund.Currency)},
+		{"Created", formatTime(refund.CreationDate)},
+	})
+}
+
+func formCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("form", "<payment-id>")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	formURL := a.client.PaymentFormURL(id)
+	return a.print(map[string]string{"formUrl": formURL}, [][2]string{
+		{"Form URL", formURL},
+	})
+}
+
+func waitCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("wait", "<payment-id>")
+	byRequest := fs.Bool("by-request", false, "treat the argument as a request ID")
+	interval := fs.Duration("interval", qi.DefaultWaitInterval, "polling interval")
+	timeout := fs.Duration("timeout", 10*time.Minute, "maximum time to wait")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	var status *qi.PaymentStatusResponse
+	if *byRequest {
+		status, err = a.client.WaitForPaymentByRequest(ctx, id, *interval)
+	} else {
+		status, err = a.client.WaitForPayment(ctx, id, *interval)
+	}
+	if err != nil {
+		return err
+	}
+	return a.printStatus(status)
+}
+
+func verifyWebhookCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("verify-webhook", "<body-file>")
+	keyPath := fs.String("key", "", "path to the gateway's PEM encoded public key")
+	signature := fs.String("signature", "", "value of the X-Signature header")
+	signatureFile := fs.String("signature-file", "", "file containing the X-Signature header value")
+	bodyPath, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyPath == "" || (*signature == "") == (*signatureFile == "") {
+		fs.Usage()
+		return errUsage
+	}
+
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	publicKey, err := qi.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return err
+	}
+	if *signatureFile != "" {
+		data, err := os.ReadFile(*signatureFile)
+		if err != nil {
+			return err
+		}
+		*signature = strings.TrimSpace(string(data))
+	}
+
+	data, err := qi.NotificationSignatureData(body)
+	if err != nil {
+		return err
+	}
+	if err := qi.VerifyNotification(publicKey, body, *signature); err != nil {
+		return err
+	}
+	return a.print(map[string]interface{}{"valid": true, "signedData": data}, [][2]string{
+		{"Valid", "true"},
+		{"Signed data", data},
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+// request is a request received by the test server.
+type request struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+// newServer starts a server responding to every request with respond, and
+// returns a function returning the requests received so far.
+func newServer(t *testing.T, respond func(n int, r *http.Request) interface{}) func() []request {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := request{method: r.Method, path: r.URL.Path}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &req.body); err != nil {
+				t.Errorf("invalid request body %s: %v", data, err)
+			}
+		}
+
+		mu.Lock()
+		requests = append(requests, req)
+		n := len(requests)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respond(n, r))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("QI_TERMINAL_ID", "test-terminal")
+	t.Setenv("QI_BASE_URL", server.URL)
+	return func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func runCommand(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-config", ""}, args...), &stdout, &stderr)
+	if code != exitOK {
+		t.Logf("stderr: %s", stderr.String())
+	}
+	return code, stdout.String()
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestCreateCommand(t *testing.T) {
+	requests := newServer(t, func(n int, r *http.Request) interface{} {
+		return qi.Payment{
+			RequestID: "r1",
+			PaymentID: "p1",
+			Status:    qi.PaymentStatusCreated,
+			Amount:    250,
+			Currency:  qi.CurrencyIQD,
+			FormURL:   "https://pay.example/p1",
+		}
+	})
+
+	code, out := runCommand(t, "create", "-amount", "250", "-finish-url", "https://shop.example/done")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(out, "https://pay.example/p1") || !strings.Contains(out, "250.00 IQD") {
+		t.Errorf("expected form URL and amount in output, got %q", out)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0].method != http.MethodPost || reqs[0].path != "/payment" {
+		t.Fatalf("unexpected requests %+v", reqs)
+	}
+	body := reqs[0].body
+	if body["amount"] != 250.0 || body["currency"] != "IQD" || body["finishPaymentUrl"] != "https://shop.example/done" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if id, _ := body["requestId"].(string); !uuidPattern.MatchString(id) {
+		t.Errorf("expected a random UUID request ID, got %q", id)
+	}
+
+	if code, _ := runCommand(t, "create"); code != exitUsage {
+		t.Errorf("expected exit code %d without an amount, got %d", exitUsage, code)
+	}
+}
+
+func TestCancelCommand(t *testing.T) {
+	requests := newServer(t, func(n int, r *http.Request) interface{} {
+		return qi.PaymentCancelResponse{RequestID: "c1", PaymentID: "p1", Status: qi.PaymentStatusSuccess, Canceled: true, Amount: 50, Currency: qi.CurrencyIQD}
+	})
+
+	code, out := runCommand(t, "-o", "json", "cancel", "-request-id", "c1", "-amount", "50", "p1")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(out, `"canceled": true`) {
+		t.Errorf("expected JSON output, got %q", out)
+	}
+	if code, _ := runCommand(t, "cancel", "-by-request", "r1"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(reqs))
+	}
+	if reqs[0].path != "/payment/p1/cancel" || reqs[0].body["requestId"] != "c1" || reqs[0].body["amount"] != 50.0 {
+		t.Errorf("unexpected request %+v", reqs[0])
+	}
+	if reqs[1].path != "/payment/cancel/by/request/r1" || !uuidPattern.MatchString(reqs[1].body["requestId"].(string)) {
+		t.Errorf("unexpected request %+v", reqs[1])
+	}
+}
+
+func TestRefundCommand(t *testing.T) {
+	requests := newServer(t, func(n int, r *http.Request) interface{} {
+		return qi.Refund{RefundID: "rf1", RequestID: "f1", PaymentID: "p1", Status: qi.RefundStatusSuccess, Amount: 25, Currency: qi.CurrencyIQD}
+	})
+
+	code, out := runCommand(t, "refund", "-request-id", "f1", "-amount", "25", "-message", "damaged", "-phone", "+964 770 123 4567", "-recipient-bank", "QIB", "p1")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(out, "rf1") {
+		t.Errorf("expected refund ID in output, got %q", out)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0].path != "/payment/p1/refund" {
+		t.Fatalf("unexpected requests %+v", reqs)
+	}
+	body := reqs[0].body
+	if body["requestId"] != "f1" || body["amount"] != 25.0 || body["message"] != "damaged" {
+		t.Errorf("unexpected body %v", body)
+	}
+	ext, _ := body["extParams"].(map[string]interface{})
+	if ext["phone"] != "9647701234567" || ext["recipientBankId"] != "QIB" {
+		t.Errorf("unexpected ext params %v", ext)
+	}
+}
+
+func TestWaitCommand(t *testing.T) {
+	requests := newServer(t, func(n int, r *http.Request) interface{} {
+		status := qi.PaymentStatusStarted
+		if n == 3 {
+			status = qi.PaymentStatusSuccess
+		}
+		return qi.PaymentStatusResponse{PaymentID: "p1", Status: status, Amount: 100, Currency: qi.CurrencyIQD}
+	})
+
+	code, out := runCommand(t, "wait", "-interval", "1ms", "p1")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(out, "SUCCESS") {
+		t.Errorf("expected terminal status in output, got %q", out)
+	}
+	if reqs := requests(); len(reqs) != 3 || reqs[0].path != "/payment/p1/status" {
+		t.Errorf("expected 3 status polls, got %+v", reqs)
+	}
+
+	if code, _ := runCommand(t, "wait", "-interval", "1ms", "-timeout", "5ms", "-by-request", "r1"); code != exitTimeout {
+		t.Errorf("expected exit code %d on timeout, got %d", exitTimeout, code)
+	}
+}
+
+func TestVerifyWebhookCommand(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"paymentId":"p1","amount":256.8,"creationDate":"2024-08-04T15:34:33","status":"SUCCESS"}`)
+	data, err := qi.NotificationSignatureData(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(data))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	bodyPath := filepath.Join(dir, "body.json")
+	signaturePath := filepath.Join(dir, "signature")
+	for path, data := range map[string][]byte{
+		keyPath:       pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		bodyPath:      body,
+		signaturePath: []byte(signature + "\n"),
+	} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-key", keyPath, "-signature", signature, bodyPath}, exitOK},
+		{[]string{"-key", keyPath, "-signature-file", signaturePath, bodyPath}, exitOK},
+		{[]string{"-key", keyPath, "-signature", base64.StdEncoding.EncodeToString([]byte("forged")), bodyPath}, exitInvalidSignature},
+		{[]string{"-key", keyPath, bodyPath}, exitUsage},
+	}
+	for _, tt := range tests {
+		code, out := runCommand(t, append([]string{"verify-webhook"}, tt.args...)...)
+		if code != tt.code {
+			t.Errorf("%v: expected exit code %d, got %d", tt.args, tt.code, code)
+		}
+		if tt.code == exitOK && !strings.Contains(out, data) {
+			t.Errorf("%v: expected signed data in output, got %q", tt.args, out)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BynxDev/qi"
+)
+
+// config contains the client settings read from the config file and the
+// environment.
+type config struct {
+	TerminalID string `json:"terminalId"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Signature  string `json:"signature"`
+	BaseURL    string `json:"baseUrl"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "qi", "config.json")
+}
+
+// loadConfig reads the config file at path, if it exists, and applies the
+// environment variables on top of it.
+func loadConfig(path string) (*config, error) {
+	var cfg config
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+			}
+		}
+	}
+
+	for env, field := range map[string]*string{
+		"QI_TERMINAL_ID": &cfg.TerminalID,
+		"QI_USERNAME":    &cfg.Username,
+		"QI_PASSWORD":    &cfg.Password,
+		"QI_SIGNATURE":   &cfg.Signature,
+		"QI_BASE_URL":    &cfg.BaseURL,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+
+	if cfg.TerminalID == "" {
+		return nil, errors.New("no terminal ID: set QI_TERMINAL_ID or terminalId in the config file")
+	}
+	return &cfg, nil
+}
+
+func (c *config) client() *qi.Client {
+	var opts []qi.ClientOption
+	if c.BaseURL != "" {
+		opts = append(opts, qi.WithBaseURL(c.BaseURL))
+	}
+	if c.Username != "" {
+		opts = append(opts, qi.WithBasicAuth(c.Username, c.Password))
+	}
+	if c.Signature != "" {
+		opts = append(opts, qi.WithSignature(c.Signature))
+	}
+	return qi.NewClient(c.TerminalID, opts...)
+}
@@ -0,0 +1,149 @@
+// Command qi is a command-line tool for operating on QiCard Payment Gateway
+// payments.
+//
+// Usage:
+//
+//	qi [-config file] [-o table|json] <command> [arguments]
+//
+// Credentials are read from the QI_TERMINAL_ID, QI_USERNAME, QI_PASSWORD,
+// QI_SIGNATURE and QI_BASE_URL environment variables, which override the
+// values in the JSON config file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/BynxDev/qi"
+)
+
+// Exit codes. API errors carrying a known ErrorCode exit with
+// exitAPIErrorBase plus the code, e.g. 112 for PAYMENT_NOT_FOUND, as long as
+// the sum fits in an exit status. Other API errors exit with exitAPIError.
+const (
+	exitOK               = 0
+	exitError            = 1
+	exitUsage            = 2
+	exitAPIError         = 3
+	exitValidation       = 4
+	exitInvalidSignature = 5
+	exitTimeout          = 6
+	exitAPIErrorBase     = 100
+	exitMax              = 255
+)
+
+const usage = `Usage: qi [-config file] [-o table|json] <command> [arguments]
+
+Commands:
+  create          create a payment
+  status          get the status of a payment
+  cancel          cancel a payment
+  confirm         confirm (capture) an authorized payment
+  refund          refund a payment
+  form            print the payment form URL
+  wait            wait until a payment reaches a terminal status
+  verify-webhook  verify a notification body against its signature
+
+Run "qi <command> -h" for the arguments of a command.
+`
+
+// errUsage is returned by commands when their arguments are invalid.
+var errUsage = errors.New("invalid arguments")
+
+type app struct {
+	client *qi.Client
+	out    io.Writer
+	errOut io.Writer
+	format string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("qi", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	configPath := fs.String("config", defaultConfigPath(), "path to the JSON config file")
+	format := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 || (*format != "table" && *format != "json") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	commands := map[string]func(context.Context, *app, []string) error{
+		"create":         createCommand,
+		"status":         statusCommand,
+		"cancel":         cancelCommand,
+		"confirm":        confirmCommand,
+		"refund":         refundCommand,
+		"form":           formCommand,
+		"wait":           waitCommand,
+		"verify-webhook": verifyWebhookCommand,
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "qi: unknown command %q\n", name)
+		fs.Usage()
+		return exitUsage
+	}
+
+	a := &app{out: stdout, errOut: stderr, format: *format}
+	if name != "verify-webhook" {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "qi: %v\n", err)
+			return exitError
+		}
+		a.client = cfg.client()
+	}
+
+	err := cmd(ctx, a, fs.Args()[1:])
+	if err != nil && !errors.Is(err, flag.ErrHelp) && !errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "qi: %v\n", err)
+	}
+	return exitCode(err)
+}
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var apiErr *qi.APIError
+	var validationErr *qi.ValidationError
+	var balanceErr *qi.BalanceError
+	switch {
+	case errors.Is(err, flag.ErrHelp), errors.Is(err, errUsage):
+		return exitUsage
+	case errors.As(err, &apiErr):
+		if apiErr.Err != nil {
+			code := apiErr.Err.Error.Code
+			if code.IsKnown() && code > 0 && exitAPIErrorBase+int(code) <= exitMax {
+				return exitAPIErrorBase + int(code)
+			}
+		}
+		return exitAPIError
+	case errors.As(err, &validationErr), errors.As(err, &balanceErr):
+		return exitValidation
+	case errors.Is(err, qi.ErrInvalidSignature):
+		return exitInvalidSignature
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	}
+	return exitError
+}
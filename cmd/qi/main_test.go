@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		codes := map[string]qi.ErrorCode{
+			"/payment/missing/status":      qi.ErrorCodePaymentNotFound,
+			"/payment/unknown-code/status": 99,
+			// 100 + 156 would wrap around to exit status 0.
+			"/payment/large-code/status": 156,
+		}
+		if code, ok := codes[r.URL.Path]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(qi.Error{Error: qi.ErrorDetails{
+				Code:    code,
+				Message: qi.ErrorMessagePaymentNotFound,
+			}})
+			return
+		}
+		json.NewEncoder(w).Encode(qi.PaymentStatusResponse{
+			PaymentID: "test-payment-id",
+			Status:    qi.PaymentStatusSuccess,
+			Amount:    100,
+			Currency:  "IQD",
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("QI_TERMINAL_ID", "test-terminal")
+	t.Setenv("QI_BASE_URL", server.URL)
+
+	tests := []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{"-config", "", "status", "test-payment-id"}, exitOK, "SUCCESS"},
+		{[]string{"-config", "", "-o", "json", "status", "test-payment-id"}, exitOK, `"status": "SUCCESS"`},
+		{[]string{"-config", "", "status", "missing"}, exitAPIErrorBase + int(qi.ErrorCodePaymentNotFound), ""},
+		{[]string{"-config", "", "status", "unknown-code"}, exitAPIError, ""},
+		{[]string{"-config", "", "status", "large-code"}, exitAPIError, ""},
+		{[]string{"-config", "", "status"}, exitUsage, ""},
+		{[]string{"-config", "", "unknown"}, exitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), tt.args, &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v: expected exit code %d, got %d (%s)", tt.args, tt.code, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.output) {
+			t.Errorf("%v: expected output to contain %q, got %q", tt.args, tt.output, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/BynxDev/qi"
+)
+
+// print writes v as indented JSON or rows as a two-column table, depending
+// on the output format.
+func (a *app) print(v interface{}, rows [][2]string) error {
+	if a.format == "json" {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+func (a *app) printStatus(status *qi.PaymentStatusResponse) error {
+	rows := [][2]string{
+		{"Request ID", status.RequestID},
+		{"Payment ID", status.PaymentID},
+		{"Status", string(status.Status)},
+		{"Canceled", strconv.FormatBool(status.Canceled)},
+		{"Amount", formatAmount(status.Amount, status.Currency)},
+		{"Confirmed", formatAmount(status.ConfirmedAmount, status.Currency)},
+		{"Payment type", string(status.PaymentType)},
+		{"Created", formatTime(status.CreationDate)},
+	}
+	if d := status.Details; d != nil {
+		rows = append(rows,
+			[2]string{"Result code", d.ResultCode},
+			[2]string{"RRN", d.RRN},
+			[2]string{"Auth ID", d.AuthID},
+			[2]string{"Masked PAN", d.MaskedPan},
+			[2]string{"Payment system", string(d.PaymentSystem)},
+		)
+	}
+	return a.print(status, rows)
+}
+
+func formatAmount(amount float64, currency qi.Currency) string {
+	if amount == 0 {
+		return ""
+	}
+	return qi.FormatAmount(amount) + " " + string(currency)
+}
+
+func formatTime(t qi.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,113 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestConfirmPayment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if r.URL.Path != "/payment/test-payment-id/confirm" {
+			t.Errorf("expected /payment/test-payment-id/confirm, got %s", r.URL.Path)
+		}
+
+		var req qi.ConfirmPaymentRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Amount != 60 {
+			t.Errorf("expected amount 60, got %v", req.Amount)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.PaymentStatusResponse{
+			PaymentID:       "test-payment-id",
+			Status:          qi.PaymentStatusSuccess,
+			Amount:          100,
+			ConfirmedAmount: 60,
+			Currency:        qi.CurrencyIQD,
+		})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	status, err := client.ConfirmPayment(context.Background(), "test-payment-id", &qi.ConfirmPaymentRequest{
+		RequestID: "confirm-request-id",
+		Amount:    60,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.IsSettled() || status.AwaitingConfirmation() {
+		t.Errorf("expected settled payment, got %+v", status)
+	}
+}
+
+func TestConfirmPaths(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.PaymentStatusResponse{Status: qi.PaymentStatusSuccess})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithConfirmPaths(qi.ConfirmPaths{Confirm: "/payment/{id}/capture"}),
+	)
+
+	ctx := context.Background()
+	if _, err := client.ConfirmPayment(ctx, "a/b", &qi.ConfirmPaymentRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ConfirmPaymentByRequest(ctx, "..", &qi.ConfirmPaymentRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/payment/a%2Fb/capture", "/payment/confirm/by/request/%2E%2E"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, paths)
+	}
+}
+
+func TestConfirmPaymentNegativeAmount(t *testing.T) {
+	client := qi.NewClient("test-terminal", qi.WithBaseURL("http://qi.invalid"))
+
+	_, err := client.ConfirmPaymentByRequest(context.Background(), "test-request-id", &qi.ConfirmPaymentRequest{Amount: -1})
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestAwaitingConfirmation(t *testing.T) {
+	tests := []struct {
+		status   qi.PaymentStatusResponse
+		awaiting bool
+		settled  bool
+	}{
+		{qi.PaymentStatusResponse{Status: qi.PaymentStatusSuccess, Amount: 100}, true, false},
+		{qi.PaymentStatusResponse{Status: qi.PaymentStatusSuccess, Amount: 100, ConfirmedAmount: 100}, false, true},
+		{qi.PaymentStatusResponse{Status: qi.PaymentStatusSuccess, Amount: 100, ConfirmedAmount: 40}, false, true},
+		{qi.PaymentStatusResponse{Status: qi.PaymentStatusSuccess, Canceled: true}, false, false},
+		{qi.PaymentStatusResponse{Status: qi.PaymentStatusFormShowed}, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.AwaitingConfirmation(); got != tt.awaiting {
+			t.Errorf("%+v: expected AwaitingConfirmation %v, got %v", tt.status, tt.awaiting, got)
+		}
+		if got := tt.status.IsSettled(); got != tt.settled {
+			t.Errorf("%+v: expected IsSettled %v, got %v", tt.status, tt.settled, got)
+		}
+	}
+}
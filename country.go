@@ -0,0 +1,88 @@
+package qi
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed iso3166.csv
+var iso3166Table string
+
+// Country is an ISO 3166-1 alpha-2 or alpha-3 country code.
+type Country string
+
+const (
+	CountryIraq Country = "IQ"
+)
+
+// CountryInfo describes a country in the ISO 3166-1 table.
+type CountryInfo struct {
+	Alpha2  Country
+	Alpha3  Country
+	Numeric int
+	Name    string
+}
+
+var countries, countriesByNumeric = parseCountries(iso3166Table)
+
+func parseCountries(table string) (map[Country]CountryInfo, map[int]CountryInfo) {
+	byCode := make(map[Country]CountryInfo)
+	byNumeric := make(map[int]CountryInfo)
+	for _, line := range strings.Split(strings.TrimSpace(table), "\n") {
+		fields := strings.SplitN(line, ",", 4)
+		if len(fields) != 4 {
+			panic("qi: malformed ISO 3166 table line: " + line)
+		}
+		numeric, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic("qi: malformed ISO 3166 numeric code: " + line)
+		}
+		info := CountryInfo{Alpha2: Country(fields[0]), Alpha3: Country(fields[1]), Numeric: numeric, Name: fields[3]}
+		byCode[info.Alpha2] = info
+		byCode[info.Alpha3] = info
+		byNumeric[numeric] = info
+	}
+	return byCode, byNumeric
+}
+
+// LookupCountry returns the country with the given alpha-2 or alpha-3 code.
+func LookupCountry(code string) (CountryInfo, bool) {
+	info, ok := countries[Country(code)]
+	return info, ok
+}
+
+// LookupCountryNumeric returns the country with the given numeric code.
+func LookupCountryNumeric(numeric int) (CountryInfo, bool) {
+	info, ok := countriesByNumeric[numeric]
+	return info, ok
+}
+
+// Info returns the ISO 3166-1 entry of the country.
+func (c Country) Info() (CountryInfo, bool) {
+	info, ok := countries[c]
+	return info, ok
+}
+
+// IsKnown returns true if c is an alpha-2 or alpha-3 code in the ISO 3166-1
+// table.
+func (c Country) IsKnown() bool {
+	_, ok := countries[c]
+	return ok
+}
+
+// Alpha2 returns the alpha-2 code of the country, or c if it is unknown.
+func (c Country) Alpha2() Country {
+	if info, ok := countries[c]; ok {
+		return info.Alpha2
+	}
+	return c
+}
+
+// Alpha3 returns the alpha-3 code of the country, or c if it is unknown.
+func (c Country) Alpha3() Country {
+	if info, ok := countries[c]; ok {
+		return info.Alpha3
+	}
+	return c
+}
@@ -0,0 +1,108 @@
+package qi
+
+import (
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//go:embed iso4217.csv
+var iso4217Table string
+
+// Currency is an ISO 4217 alphabetic currency code.
+type Currency string
+
+const (
+	CurrencyIQD Currency = "IQD"
+	CurrencyUSD Currency = "USD"
+)
+
+// CurrencyInfo describes a currency in the ISO 4217 table. MinorUnits is
+// informational: amounts in the API always have AmountDecimals decimal
+// places.
+type CurrencyInfo struct {
+	Code       Currency
+	Numeric    int
+	MinorUnits int
+	Name       string
+}
+
+var currencies, currenciesByNumeric = parseCurrencies(iso4217Table)
+
+func parseCurrencies(table string) (map[Currency]CurrencyInfo, map[int]CurrencyInfo) {
+	byCode := make(map[Currency]CurrencyInfo)
+	byNumeric := make(map[int]CurrencyInfo)
+	for _, line := range strings.Split(strings.TrimSpace(table), "\n") {
+		fields := strings.SplitN(line, ",", 4)
+		if len(fields) != 4 {
+			panic("qi: malformed ISO 4217 table line: " + line)
+		}
+		numeric, err := strconv.Atoi(fields[1])
+		if err != nil {
+			panic("qi: malformed ISO 4217 numeric code: " + line)
+		}
+		minor, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic("qi: malformed ISO 4217 minor units: " + line)
+		}
+		info := CurrencyInfo{Code: Currency(fields[0]), Numeric: numeric, MinorUnits: minor, Name: fields[3]}
+		byCode[info.Code] = info
+		byNumeric[numeric] = info
+	}
+	return byCode, byNumeric
+}
+
+// LookupCurrency returns the currency with the given alphabetic code.
+func LookupCurrency(code string) (CurrencyInfo, bool) {
+	info, ok := currencies[Currency(code)]
+	return info, ok
+}
+
+// LookupCurrencyNumeric returns the currency with the given numeric code.
+func LookupCurrencyNumeric(numeric int) (CurrencyInfo, bool) {
+	info, ok := currenciesByNumeric[numeric]
+	return info, ok
+}
+
+// Info returns the ISO 4217 entry of the currency.
+func (c Currency) Info() (CurrencyInfo, bool) {
+	info, ok := currencies[c]
+	return info, ok
+}
+
+// IsKnown returns true if c is in the ISO 4217 table.
+func (c Currency) IsKnown() bool {
+	_, ok := currencies[c]
+	return ok
+}
+
+// AmountDecimals is the number of decimal places of amounts in the API,
+// which represents every amount in the format "0.00" whatever the currency,
+// including IQD with 3 minor units in ISO 4217.
+const AmountDecimals = 2
+
+// amountScale is the number of amount units in one unit of currency.
+const amountScale = 100
+
+// AmountUnits converts an amount to hundredths, the smallest amount the API
+// represents, so that amounts can be summed and compared without floating
+// point error.
+func AmountUnits(amount float64) int64 {
+	return int64(math.Round(amount * amountScale))
+}
+
+// AmountFromUnits converts hundredths to an amount.
+func AmountFromUnits(units int64) float64 {
+	return float64(units) / amountScale
+}
+
+// RoundAmount rounds an amount to AmountDecimals decimal places.
+func RoundAmount(amount float64) float64 {
+	return AmountFromUnits(AmountUnits(amount))
+}
+
+// FormatAmount formats an amount in the "0.00" format of the API.
+func FormatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', AmountDecimals, 64)
+}
@@ -0,0 +1,133 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestLookupCurrency(t *testing.T) {
+	info, ok := qi.LookupCurrency("IQD")
+	if !ok {
+		t.Fatal("expected IQD to be known")
+	}
+	if info.Numeric != 368 || info.MinorUnits != 3 || info.Name != "Iraqi Dinar" {
+		t.Errorf("unexpected IQD entry %+v", info)
+	}
+
+	info, ok = qi.LookupCurrencyNumeric(840)
+	if !ok || info.Code != qi.CurrencyUSD {
+		t.Errorf("expected numeric 840 to be USD, got %+v", info)
+	}
+
+	if qi.Currency("XYZ").IsKnown() {
+		t.Error("expected XYZ to be unknown")
+	}
+}
+
+func TestAmounts(t *testing.T) {
+	tests := []struct {
+		amount float64
+		units  int64
+		text   string
+	}{
+		{1250.5, 125050, "1250.50"},
+		{10.255, 1026, "10.26"},
+		{25000, 2500000, "25000.00"},
+		{0.1 + 0.2, 30, "0.30"},
+	}
+
+	for _, tt := range tests {
+		if got := qi.AmountUnits(tt.amount); got != tt.units {
+			t.Errorf("%v: expected %d units, got %d", tt.amount, tt.units, got)
+		}
+		if got := qi.FormatAmount(qi.RoundAmount(tt.amount)); got != tt.text {
+			t.Errorf("%v: expected %s, got %s", tt.amount, tt.text, got)
+		}
+	}
+}
+
+// The API formats every amount as "0.00", so the receipt, balance and
+// notification signature calculations all use two decimals, even for IQD.
+func TestAmountPrecision(t *testing.T) {
+	info := &qi.ItemsInfo{Items: []qi.PaymentItem{{Price: 1000.01, Quantity: 1, Amount: 1000.01, Tax: qi.ItemTaxVAT10}}}
+	if err := info.Validate(1000.01); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if vat := info.VAT(); vat != 90.91 {
+		t.Errorf("expected VAT 90.91, got %v", vat)
+	}
+
+	balance := qi.NewPaymentBalance(&qi.PaymentStatusResponse{
+		Status:          qi.PaymentStatusSuccess,
+		Amount:          25000.005,
+		ConfirmedAmount: 25000.005,
+		Currency:        qi.CurrencyIQD,
+	}, []qi.Refund{{Amount: 0.004}})
+	if balance.Refundable() != 25000.01 {
+		t.Errorf("expected refundable 25000.01, got %v", balance.Refundable())
+	}
+
+	data, err := qi.NotificationSignatureData([]byte(`{"paymentId":"p1","amount":25000,"currency":"IQD","creationDate":"2024-08-04T15:34:33","status":"SUCCESS"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "p1|25000.00|IQD|2024-08-04T15:34:33|SUCCESS"; data != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+}
+
+func TestCurrencyUnmarshalJSON(t *testing.T) {
+	var payment qi.Payment
+	if err := json.Unmarshal([]byte(`{"currency":"USD"}`), &payment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payment.Currency != qi.CurrencyUSD {
+		t.Errorf("expected USD, got %s", payment.Currency)
+	}
+
+	// Responses and notifications with currencies missing from the table are
+	// decoded and reported to the unknown hook instead.
+	if err := json.Unmarshal([]byte(`{"currency":"XYZ"}`), &payment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payment.Currency != "XYZ" || payment.Currency.IsKnown() {
+		t.Errorf("expected unknown currency XYZ, got %s", payment.Currency)
+	}
+}
+
+func TestUnknownCurrencyHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"paymentId":"p1","status":"SUCCESS","amount":100,"currency":"XYZ"}`)
+	}))
+	defer server.Close()
+
+	var seen []qi.Unknown
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithUnknownHook(func(ctx context.Context, u qi.Unknown) {
+			seen = append(seen, u)
+		}),
+	)
+	if _, err := client.GetPaymentStatus(context.Background(), "p1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 1 || seen[0] != (qi.Unknown{Type: "Currency", Field: "currency", Value: "XYZ"}) {
+		t.Errorf("expected unknown currency to be reported, got %+v", seen)
+	}
+}
+
+func TestCreatePaymentUnknownCurrency(t *testing.T) {
+	req := &qi.CreatePaymentRequest{RequestID: "r-1", Amount: 100, Currency: "XYZ"}
+	var validationErr *qi.ValidationError
+	if err := req.Validate(); !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package qi
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDedupCapacity is the default number of payments remembered by a
+// MemoryDedupStore.
+const DefaultDedupCapacity = 10000
+
+// DedupStore records which payment statuses have been dispatched by a
+// NotificationHandler, keyed by payment ID and status.
+type DedupStore interface {
+	// Statuses returns the statuses recorded for a payment.
+	Statuses(ctx context.Context, paymentID string) ([]PaymentStatus, error)
+	// Add records a status for a payment. It returns false if the status was
+	// already recorded.
+	Add(ctx context.Context, paymentID string, status PaymentStatus) (bool, error)
+	// Remove deletes a recorded status, so that the notification can be
+	// dispatched again.
+	Remove(ctx context.Context, paymentID string, status PaymentStatus) error
+}
+
+// MemoryDedupStore is an in-memory DedupStore that forgets the least recently
+// notified payments once its capacity is reached.
+type MemoryDedupStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	paymentID string
+	statuses  []PaymentStatus
+}
+
+// NewMemoryDedupStore creates a new MemoryDedupStore remembering up to
+// capacity payments. A capacity of zero or less uses DefaultDedupCapacity.
+func NewMemoryDedupStore(capacity int) *MemoryDedupStore {
+	if capacity <= 0 {
+		capacity = DefaultDedupCapacity
+	}
+	return &MemoryDedupStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Statuses implements the DedupStore interface.
+func (s *MemoryDedupStore) Statuses(ctx context.Context, paymentID string) ([]PaymentStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[paymentID]
+	if !ok {
+		return nil, nil
+	}
+	s.order.MoveToFront(el)
+	statuses := el.Value.(*dedupEntry).statuses
+	return append([]PaymentStatus(nil), statuses...), nil
+}
+
+// Add implements the DedupStore interface.
+func (s *MemoryDedupStore) Add(ctx context.Context, paymentID string, status PaymentStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[paymentID]
+	if !ok {
+		el = s.order.PushFront(&dedupEntry{paymentID: paymentID})
+		s.entries[paymentID] = el
+		if s.order.Len() > s.capacity {
+			oldest := s.order.Back()
+			s.order.Remove(oldest)
+			delete(s.entries, oldest.Value.(*dedupEntry).paymentID)
+		}
+	}
+	s.order.MoveToFront(el)
+
+	entry := el.Value.(*dedupEntry)
+	for _, st := range entry.statuses {
+		if st == status {
+			return false, nil
+		}
+	}
+	entry.statuses = append(entry.statuses, status)
+	return true, nil
+}
+
+// Remove implements the DedupStore interface.
+func (s *MemoryDedupStore) Remove(ctx context.Context, paymentID string, status PaymentStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[paymentID]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*dedupEntry)
+	for i, st := range entry.statuses {
+		if st == status {
+			entry.statuses = append(entry.statuses[:i], entry.statuses[i+1:]...)
+			break
+		}
+	}
+	if len(entry.statuses) == 0 {
+		s.order.Remove(el)
+		delete(s.entries, paymentID)
+	}
+	return nil
+}
+
+// Placeholder returns the bind parameter placeholder for the n-th (1-based)
+// argument of a SQL statement.
+type Placeholder func(n int) string
+
+// QuestionPlaceholder is the "?" placeholder used by MySQL and SQLite.
+func QuestionPlaceholder(n int) string { return "?" }
+
+// DollarPlaceholder is the "$n" placeholder used by PostgreSQL.
+func DollarPlaceholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// SQLDedupStore is a DedupStore backed by a database/sql table created as:
+//
+//	CREATE TABLE qi_notification_dedup (
+//		payment_id VARCHAR(64) NOT NULL,
+//		status     VARCHAR(64) NOT NULL,
+//		created_at TIMESTAMP   NOT NULL,
+//		PRIMARY KEY (payment_id, status)
+//	);
+//
+// Statuses are recorded with a single INSERT ... ON CONFLICT DO NOTHING, as
+// supported by PostgreSQL and SQLite, so concurrent deliveries of the same
+// notification cannot both be recorded. Use WithInsertIgnore for MySQL.
+type SQLDedupStore struct {
+	db           *sql.DB
+	table        string
+	placeholder  Placeholder
+	insertIgnore bool
+}
+
+// SQLDedupOption is a function that configures a SQLDedupStore.
+type SQLDedupOption func(*SQLDedupStore)
+
+// WithInsertIgnore records statuses with INSERT IGNORE, for MySQL, instead of
+// INSERT ... ON CONFLICT DO NOTHING.
+func WithInsertIgnore() SQLDedupOption {
+	return func(s *SQLDedupStore) {
+		s.insertIgnore = true
+	}
+}
+
+// NewSQLDedupStore creates a new SQLDedupStore using the given table.
+func NewSQLDedupStore(db *sql.DB, table string, placeholder Placeholder, opts ...SQLDedupOption) *SQLDedupStore {
+	s := &SQLDedupStore{db: db, table: table, placeholder: placeholder}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Statuses implements the DedupStore interface.
+func (s *SQLDedupStore) Statuses(ctx context.Context, paymentID string) ([]PaymentStatus, error) {
+	query := fmt.Sprintf("SELECT status FROM %s WHERE payment_id = %s", s.table, s.placeholder(1))
+	rows, err := s.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dedup store: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []PaymentStatus
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			return nil, fmt.Errorf("failed to scan dedup store: %w", err)
+		}
+		statuses = append(statuses, PaymentStatus(status))
+	}
+	return statuses, rows.Err()
+}
+
+// Add implements the DedupStore interface. The status was already recorded
+// if the insert affected no rows.
+func (s *SQLDedupStore) Add(ctx context.Context, paymentID string, status PaymentStatus) (bool, error) {
+	values := fmt.Sprintf("(payment_id, status, created_at) VALUES (%s, %s, %s)",
+		s.placeholder(1), s.placeholder(2), s.placeholder(3))
+	query := fmt.Sprintf("INSERT INTO %s %s ON CONFLICT (payment_id, status) DO NOTHING", s.table, values)
+	if s.insertIgnore {
+		query = fmt.Sprintf("INSERT IGNORE INTO %s %s", s.table, values)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, paymentID, string(status), time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to insert into dedup store: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert into dedup store: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Remove implements the DedupStore interface.
+func (s *SQLDedupStore) Remove(ctx context.Context, paymentID string, status PaymentStatus) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE payment_id = %s AND status = %s",
+		s.table, s.placeholder(1), s.placeholder(2))
+	if _, err := s.db.ExecContext(ctx, query, paymentID, string(status)); err != nil {
+		return fmt.Errorf("failed to delete from dedup store: %w", err)
+	}
+	return nil
+}
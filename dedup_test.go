@@ -0,0 +1,126 @@
+package qi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func newDedupDB(t *testing.T) *qi.SQLDedupStore {
+	t.Helper()
+	db := newFakeDB(t, map[string][]string{"qi_notification_dedup": {"payment_id", "status"}})
+	return qi.NewSQLDedupStore(db, "qi_notification_dedup", qi.DollarPlaceholder)
+}
+
+func TestSQLDedupStore(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []qi.SQLDedupOption
+		p    qi.Placeholder
+	}{
+		{"on conflict", nil, qi.DollarPlaceholder},
+		{"insert ignore", []qi.SQLDedupOption{qi.WithInsertIgnore()}, qi.QuestionPlaceholder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, map[string][]string{"qi_notification_dedup": {"payment_id", "status"}})
+			store := qi.NewSQLDedupStore(db, "qi_notification_dedup", tt.p, tt.opts...)
+
+			for _, want := range []bool{true, false} {
+				added, err := store.Add(ctx, "p1", qi.PaymentStatusSuccess)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if added != want {
+					t.Errorf("expected added to be %v", want)
+				}
+			}
+			if _, err := store.Add(ctx, "p1", qi.PaymentStatusStarted); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			statuses, err := store.Statuses(ctx, "p1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(statuses) != "[SUCCESS STARTED]" {
+				t.Errorf("unexpected statuses %v", statuses)
+			}
+
+			if err := store.Remove(ctx, "p1", qi.PaymentStatusSuccess); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if added, _ := store.Add(ctx, "p1", qi.PaymentStatusSuccess); !added {
+				t.Error("expected removed status to be added again")
+			}
+		})
+	}
+}
+
+func TestSQLDedupStoreConcurrentDeliveries(t *testing.T) {
+	key := newTestKey(t)
+
+	var calls int32
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		},
+		qi.WithDedupStore(newDedupDB(t)),
+	)
+
+	body := notificationBody("p1", qi.PaymentStatusSuccess)
+	signature := signNotification(t, key, body)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+			r.Header.Set("X-Signature", signature)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("expected 200, got %d", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected concurrent deliveries to be dispatched once, got %d", calls)
+	}
+}
+
+func TestNotificationHandlerConflictingStatuses(t *testing.T) {
+	key := newTestKey(t)
+
+	var dispatched []qi.PaymentStatus
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			dispatched = append(dispatched, payment.Status)
+			return nil
+		},
+		qi.WithDedupStore(newDedupDB(t)),
+	)
+
+	for _, status := range []qi.PaymentStatus{qi.PaymentStatusSuccess, qi.PaymentStatusFailed, qi.PaymentStatusCreated} {
+		body := notificationBody("p1", status)
+		r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+		r.Header.Set("X-Signature", signNotification(t, key, body))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []qi.PaymentStatus{qi.PaymentStatusSuccess, qi.PaymentStatusFailed}
+	if fmt.Sprint(dispatched) != fmt.Sprint(want) {
+		t.Errorf("expected %v dispatched, got %v", want, dispatched)
+	}
+}
@@ -1,6 +1,11 @@
 package qi
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 // ErrorCode represents an API error code.
 type ErrorCode int
@@ -42,6 +47,27 @@ const (
 	ErrorCodeInvalidTokenType                    ErrorCode = 35
 )
 
+// IsKnown returns true if c is an error code known to this version of the
+// client.
+func (c ErrorCode) IsKnown() bool {
+	switch c {
+	case ErrorCodeOrderAlreadyExists, ErrorCodeOrderNotFound, ErrorCodeOrderAlreadyCancelled,
+		ErrorCodeNoCompatibleServicesFound, ErrorCodeCanNotProcessRequest, ErrorCodeRequisitesNotFound,
+		ErrorCodeRequisitesAlreadyExists, ErrorCodeCanNotCreateNewRequisites, ErrorCodeTerminalNotFoundException,
+		ErrorCodePaymentAlreadyExists, ErrorCodeMaxNumberOfPaymentsForOrderExceeded, ErrorCodePaymentNotFound,
+		ErrorCodeUnknownStrategy, ErrorCodeProcessingImpossible, ErrorCodeCanNotCancelPayment,
+		ErrorCodeCanNotConfirmPayment, ErrorCodeCanNotFinishAuthentication, ErrorCodeRefundsNotAllowed,
+		ErrorCodePaymentParamsNotFound, ErrorCodeRefundError, ErrorCodeValidationError,
+		ErrorCodeIncorrectPaymentState, ErrorCodeInternalSystemError, ErrorCodeExternalSystemError,
+		ErrorCodeInvalidPaymentFormDomain, ErrorCodeBadCredentials, ErrorCodeLimitViolation,
+		ErrorCodeTransferNotFound, ErrorCodeIncorrectTransferState, ErrorCodeTokenNotFound,
+		ErrorCodeTokenProcessNotAllowed, ErrorCodeCanNotCancelTransfer, ErrorCodeTransferAlreadyExists,
+		ErrorCodeInvalidTokenType:
+		return true
+	}
+	return false
+}
+
 // ErrorMessage represents an API error message.
 type ErrorMessage string
 
@@ -82,6 +108,27 @@ const (
 	ErrorMessageInvalidTokenType                    ErrorMessage = "INVALID_TOKEN_TYPE"
 )
 
+// IsKnown returns true if m is an error message known to this version of the
+// client.
+func (m ErrorMessage) IsKnown() bool {
+	switch m {
+	case ErrorMessageOrderAlreadyExists, ErrorMessageOrderNotFound, ErrorMessageOrderAlreadyCancelled,
+		ErrorMessageNoCompatibleServicesFound, ErrorMessageCanNotProcessRequest, ErrorMessageRequisitesNotFound,
+		ErrorMessageRequisitesAlreadyExists, ErrorMessageCanNotCreateNewRequisites, ErrorMessageTerminalNotFoundException,
+		ErrorMessagePaymentAlreadyExists, ErrorMessageMaxNumberOfPaymentsForOrderExceeded, ErrorMessagePaymentNotFound,
+		ErrorMessageUnknownStrategy, ErrorMessageProcessingImpossible, ErrorMessageCanNotCancelPayment,
+		ErrorMessageCanNotConfirmPayment, ErrorMessageCanNotFinishAuthentication, ErrorMessageRefundsNotAllowed,
+		ErrorMessagePaymentParamsNotFound, ErrorMessageRefundError, ErrorMessageValidationError,
+		ErrorMessageIncorrectPaymentState, ErrorMessageInternalSystemError, ErrorMessageExternalSystemError,
+		ErrorMessageInvalidPaymentFormDomain, ErrorMessageBadCredentials, ErrorMessageLimitViolation,
+		ErrorMessageTransferNotFound, ErrorMessageIncorrectTransferState, ErrorMessageTokenNotFound,
+		ErrorMessageTokenProcessNotAllowed, ErrorMessageCanNotCancelTransfer, ErrorMessageTransferAlreadyExists,
+		ErrorMessageInvalidTokenType:
+		return true
+	}
+	return false
+}
+
 // Error represents an API error response.
 type Error struct {
 	Error ErrorDetails `json:"error"`
@@ -98,6 +145,8 @@ type APIError struct {
 	StatusCode int
 	Message    string
 	Err        *Error
+	// Meta describes the HTTP response that carried the error.
+	Meta *ResponseMeta
 }
 
 // Error implements the error interface.
@@ -120,6 +169,30 @@ func (e *APIError) IsNotFound() bool {
 	return e.StatusCode == 404
 }
 
+// RetryAfter returns the delay requested by the Retry-After header of the
+// response, or zero if there is none.
+func (e *APIError) RetryAfter() time.Duration {
+	if e.Meta == nil || e.Meta.Header == nil {
+		return 0
+	}
+	value := e.Meta.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 // IsValidationError returns true if the error is a validation error.
 func (e *APIError) IsValidationError() bool {
 	if e.Err != nil {
@@ -135,3 +208,15 @@ func (e *APIError) IsAuthenticationError() bool {
 	}
 	return e.StatusCode == 401
 }
+
+// IsRetryable returns true if the request may succeed when repeated, i.e. the
+// gateway reported a server-side or rate limit error.
+func (e *APIError) IsRetryable() bool {
+	if e.Err != nil {
+		switch e.Err.Error.Code {
+		case ErrorCodeInternalSystemError, ErrorCodeExternalSystemError, ErrorCodeLimitViolation:
+			return true
+		}
+	}
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
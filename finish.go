@@ -0,0 +1,209 @@
+package qi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrInvalidFinishState is returned when the state parameter of a finish
+// redirect is missing or does not match the payer's session.
+var ErrInvalidFinishState = errors.New("qi: invalid finish redirect state")
+
+var (
+	errFinishMissingID = errors.New("qi: finish redirect has neither requestId nor paymentId")
+	errFinishMismatch  = errors.New("qi: finish redirect paymentId does not match requestId")
+)
+
+// FinishRedirect contains the query parameters passed by the payment gateway
+// when redirecting the payer to finishPaymentUrl. The values come from the
+// payer's browser and must not be trusted without checking the payment status.
+type FinishRedirect struct {
+	RequestID   string
+	PaymentID   string
+	PaymentType string
+	Status      PaymentStatus
+	State       string
+}
+
+// ParseFinishRedirect parses the finish redirect parameters from a request.
+func ParseFinishRedirect(r *http.Request) (*FinishRedirect, error) {
+	q := r.URL.Query()
+	redirect := &FinishRedirect{
+		RequestID:   q.Get("requestId"),
+		PaymentID:   q.Get("paymentId"),
+		PaymentType: q.Get("paymentType"),
+		Status:      PaymentStatus(q.Get("status")),
+		State:       q.Get("state"),
+	}
+
+	if redirect.RequestID == "" && redirect.PaymentID == "" {
+		return nil, errFinishMissingID
+	}
+	return redirect, nil
+}
+
+// FinishState computes the state value binding a payment request to the
+// payer's session.
+func FinishState(key []byte, sessionID, requestID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sessionID + "|" + requestID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FinishPaymentURL returns finishURL with the state parameter added, for use
+// as CreatePaymentRequest.FinishPaymentURL.
+func FinishPaymentURL(finishURL string, key []byte, sessionID, requestID string) (string, error) {
+	u, err := url.Parse(finishURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse finish URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("state", FinishState(key, sessionID, requestID))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// FinishFunc is called with the authoritative payment status once a finish
+// redirect has been confirmed with the API.
+type FinishFunc func(w http.ResponseWriter, r *http.Request, redirect *FinishRedirect, status *PaymentStatusResponse)
+
+// FinishHandler is an http.Handler for finishPaymentUrl redirects. It ignores
+// the status passed in the redirect and queries the API for the current one.
+type FinishHandler struct {
+	client     PaymentGateway
+	onSuccess  FinishFunc
+	onFailure  FinishFunc
+	onPending  FinishFunc
+	onError    func(w http.ResponseWriter, r *http.Request, err error)
+	checkState bool
+	stateKey   []byte
+	sessionID  func(r *http.Request) string
+}
+
+// FinishHandlerOption is a function that configures a FinishHandler.
+type FinishHandlerOption func(*FinishHandler)
+
+// OnFinishSuccess sets the callback for successful payments.
+func OnFinishSuccess(fn FinishFunc) FinishHandlerOption {
+	return func(h *FinishHandler) {
+		h.onSuccess = fn
+	}
+}
+
+// OnFinishFailure sets the callback for failed, expired and canceled payments.
+func OnFinishFailure(fn FinishFunc) FinishHandlerOption {
+	return func(h *FinishHandler) {
+		h.onFailure = fn
+	}
+}
+
+// OnFinishPending sets the callback for payments that are still processing.
+func OnFinishPending(fn FinishFunc) FinishHandlerOption {
+	return func(h *FinishHandler) {
+		h.onPending = fn
+	}
+}
+
+// OnFinishError sets the callback for invalid redirects and API errors.
+// By default, the handler responds with 400 or 502.
+func OnFinishError(fn func(w http.ResponseWriter, r *http.Request, err error)) FinishHandlerOption {
+	return func(h *FinishHandler) {
+		h.onError = fn
+	}
+}
+
+// WithFinishState requires redirects to carry a state value computed by
+// FinishState for the session returned by sessionID. It panics if key is
+// empty or sessionID is nil, since either would disable the check.
+func WithFinishState(key []byte, sessionID func(r *http.Request) string) FinishHandlerOption {
+	if len(key) == 0 {
+		panic("qi: WithFinishState requires a non-empty key")
+	}
+	if sessionID == nil {
+		panic("qi: WithFinishState requires a sessionID function")
+	}
+	return func(h *FinishHandler) {
+		h.checkState = true
+		h.stateKey = key
+		h.sessionID = sessionID
+	}
+}
+
+// NewFinishHandler creates a new FinishHandler.
+func NewFinishHandler(client PaymentGateway, opts ...FinishHandlerOption) *FinishHandler {
+	h := &FinishHandler{
+		client:  client,
+		onError: defaultFinishError,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *FinishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	redirect, err := ParseFinishRedirect(r)
+	if err != nil {
+		h.onError(w, r, err)
+		return
+	}
+
+	if h.checkState {
+		want := FinishState(h.stateKey, h.sessionID(r), redirect.RequestID)
+		if redirect.RequestID == "" || !hmac.Equal([]byte(redirect.State), []byte(want)) {
+			h.onError(w, r, ErrInvalidFinishState)
+			return
+		}
+	}
+
+	var status *PaymentStatusResponse
+	if redirect.RequestID != "" {
+		status, err = h.client.GetPaymentStatusByRequest(r.Context(), redirect.RequestID)
+	} else {
+		status, err = h.client.GetPaymentStatus(r.Context(), redirect.PaymentID)
+	}
+	if err != nil {
+		h.onError(w, r, err)
+		return
+	}
+
+	if redirect.PaymentID != "" && status.PaymentID != redirect.PaymentID {
+		h.onError(w, r, errFinishMismatch)
+		return
+	}
+
+	var fn FinishFunc
+	switch {
+	case status.Status == PaymentStatusSuccess && !status.Canceled:
+		fn = h.onSuccess
+	case status.Status.IsFailure() || status.Canceled:
+		fn = h.onFailure
+	default:
+		fn = h.onPending
+	}
+
+	if fn == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	fn(w, r, redirect, status)
+}
+
+// defaultFinishError responds with 400 for invalid redirects and 502 when the
+// payment status could not be retrieved.
+func defaultFinishError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrInvalidFinishState) || errors.Is(err, errFinishMissingID) || errors.Is(err, errFinishMismatch) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
@@ -0,0 +1,151 @@
+package qi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func newStatusServer(t *testing.T, status qi.PaymentStatus) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payment/status/by/request/test-request-id" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.PaymentStatusResponse{
+			RequestID: "test-request-id",
+			PaymentID: "test-payment-id",
+			Status:    status,
+		})
+	}))
+}
+
+func TestFinishHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		status qi.PaymentStatus
+		want   string
+	}{
+		{"success", qi.PaymentStatusSuccess, "success"},
+		{"failure", qi.PaymentStatusFailed, "failure"},
+		{"pending", qi.PaymentStatusStarted, "pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStatusServer(t, tt.status)
+			defer server.Close()
+
+			var got string
+			callback := func(name string) qi.FinishFunc {
+				return func(w http.ResponseWriter, r *http.Request, redirect *qi.FinishRedirect, status *qi.PaymentStatusResponse) {
+					got = name
+				}
+			}
+
+			client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+			handler := qi.NewFinishHandler(client,
+				qi.OnFinishSuccess(callback("success")),
+				qi.OnFinishFailure(callback("failure")),
+				qi.OnFinishPending(callback("pending")),
+			)
+
+			// The redirect claims success regardless of the real status.
+			r := httptest.NewRequest(http.MethodGet, "/finish?requestId=test-request-id&paymentId=test-payment-id&status=SUCCESS", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("expected %s callback, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFinishHandlerState(t *testing.T) {
+	server := newStatusServer(t, qi.PaymentStatusSuccess)
+	defer server.Close()
+
+	key := []byte("secret")
+	session := func(r *http.Request) string { return r.Header.Get("X-Session") }
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	handler := qi.NewFinishHandler(client, qi.WithFinishState(key, session))
+
+	finishURL, err := qi.FinishPaymentURL("https://merchant.net/finish", key, "session-1", "test-request-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u, _ := url.Parse(finishURL)
+	q := u.Query()
+	q.Set("requestId", "test-request-id")
+	u.RawQuery = q.Encode()
+
+	r := httptest.NewRequest(http.MethodGet, u.String(), nil)
+	r.Header.Set("X-Session", "session-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, u.String(), nil)
+	r.Header.Set("X-Session", "session-2")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for another session, got %d", w.Code)
+	}
+}
+
+func TestFinishHandlerEscapesIDs(t *testing.T) {
+	var escaped string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escaped = r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"requestId":"x","paymentId":"test-payment-id","status":"SUCCESS"}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	handler := qi.NewFinishHandler(client)
+	tests := map[string]string{
+		"../../refunds?x=1#y": "/payment/status/by/request/..%2F..%2Frefunds%3Fx=1%23y",
+		"..":                  "/payment/status/by/request/%2E%2E",
+	}
+	for requestID, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/finish?requestId="+url.QueryEscape(requestID), nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if escaped != want {
+			t.Errorf("requestId %q: expected path %s, got %s", requestID, want, escaped)
+		}
+	}
+}
+
+func TestWithFinishStateNilSessionID(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for nil sessionID")
+		}
+	}()
+	qi.WithFinishState([]byte("key"), nil)
+}
+
+func TestWithFinishStateEmptyKey(t *testing.T) {
+	session := func(r *http.Request) string { return "session" }
+	for _, key := range [][]byte{nil, {}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for key %q", key)
+				}
+			}()
+			qi.WithFinishState(key, session)
+		}()
+	}
+}
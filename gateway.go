@@ -0,0 +1,270 @@
+package qi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PaymentGateway is the set of QiCard Payment Gateway operations. It is
+// implemented by *Client and can be mocked or decorated.
+type PaymentGateway interface {
+	CreatePayment(ctx context.Context, req *CreatePaymentRequest, opts ...CallOption) (*Payment, error)
+	GetPaymentStatus(ctx context.Context, paymentID string, opts ...CallOption) (*PaymentStatusResponse, error)
+	GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (*PaymentStatusResponse, error)
+	CancelPayment(ctx context.Context, paymentID string, req *CancelPaymentRequest, opts ...CallOption) (*PaymentCancelResponse, error)
+	CancelPaymentByRequest(ctx context.Context, requestID string, req *CancelPaymentRequest, opts ...CallOption) (*PaymentCancelResponse, error)
+	ConfirmPayment(ctx context.Context, paymentID string, req *ConfirmPaymentRequest, opts ...CallOption) (*PaymentStatusResponse, error)
+	ConfirmPaymentByRequest(ctx context.Context, requestID string, req *ConfirmPaymentRequest, opts ...CallOption) (*PaymentStatusResponse, error)
+	RefundPayment(ctx context.Context, paymentID string, req *CreateRefundRequest, opts ...CallOption) (*Refund, error)
+	RefundPaymentByRequest(ctx context.Context, requestID string, req *CreateRefundRequest, opts ...CallOption) (*Refund, error)
+	CreateTransfer(ctx context.Context, req *CreateTransferRequest, opts ...CallOption) (*Transfer, error)
+	GetTransferStatus(ctx context.Context, transferID string, opts ...CallOption) (*Transfer, error)
+	GetTransferStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (*Transfer, error)
+	CancelTransfer(ctx context.Context, transferID string, req *CancelTransferRequest, opts ...CallOption) (*Transfer, error)
+	CancelTransferByRequest(ctx context.Context, requestID string, req *CancelTransferRequest, opts ...CallOption) (*Transfer, error)
+}
+
+var _ PaymentGateway = (*Client)(nil)
+
+// Operation names passed to a Middleware.
+const (
+	OperationCreatePayment              = "CreatePayment"
+	OperationGetPaymentStatus           = "GetPaymentStatus"
+	OperationGetPaymentStatusByRequest  = "GetPaymentStatusByRequest"
+	OperationCancelPayment              = "CancelPayment"
+	OperationCancelPaymentByRequest     = "CancelPaymentByRequest"
+	OperationConfirmPayment             = "ConfirmPayment"
+	OperationConfirmPaymentByRequest    = "ConfirmPaymentByRequest"
+	OperationRefundPayment              = "RefundPayment"
+	OperationRefundPaymentByRequest     = "RefundPaymentByRequest"
+	OperationCreateTransfer             = "CreateTransfer"
+	OperationGetTransferStatus          = "GetTransferStatus"
+	OperationGetTransferStatusByRequest = "GetTransferStatusByRequest"
+	OperationCancelTransfer             = "CancelTransfer"
+	OperationCancelTransferByRequest    = "CancelTransferByRequest"
+)
+
+// Middleware wraps a call to a PaymentGateway operation. It must call call
+// to perform the operation, and return its error unless it handles it.
+type Middleware func(ctx context.Context, operation string, call func(ctx context.Context) error) error
+
+// WrapGateway returns a PaymentGateway that passes every call to gw through
+// the middlewares. The first middleware is the outermost one.
+func WrapGateway(gw PaymentGateway, middlewares ...Middleware) PaymentGateway {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		gw = &wrappedGateway{next: gw, mw: middlewares[i]}
+	}
+	return gw
+}
+
+type wrappedGateway struct {
+	next PaymentGateway
+	mw   Middleware
+}
+
+func (g *wrappedGateway) CreatePayment(ctx context.Context, req *CreatePaymentRequest, opts ...CallOption) (resp *Payment, err error) {
+	err = g.mw(ctx, OperationCreatePayment, func(ctx context.Context) error {
+		resp, err = g.next.CreatePayment(ctx, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) GetPaymentStatus(ctx context.Context, paymentID string, opts ...CallOption) (resp *PaymentStatusResponse, err error) {
+	err = g.mw(ctx, OperationGetPaymentStatus, func(ctx context.Context) error {
+		resp, err = g.next.GetPaymentStatus(ctx, paymentID, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (resp *PaymentStatusResponse, err error) {
+	err = g.mw(ctx, OperationGetPaymentStatusByRequest, func(ctx context.Context) error {
+		resp, err = g.next.GetPaymentStatusByRequest(ctx, requestID, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) CancelPayment(ctx context.Context, paymentID string, req *CancelPaymentRequest, opts ...CallOption) (resp *PaymentCancelResponse, err error) {
+	err = g.mw(ctx, OperationCancelPayment, func(ctx context.Context) error {
+		resp, err = g.next.CancelPayment(ctx, paymentID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) CancelPaymentByRequest(ctx context.Context, requestID string, req *CancelPaymentRequest, opts ...CallOption) (resp *PaymentCancelResponse, err error) {
+	err = g.mw(ctx, OperationCancelPaymentByRequest, func(ctx context.Context) error {
+		resp, err = g.next.CancelPaymentByRequest(ctx, requestID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) ConfirmPayment(ctx context.Context, paymentID string, req *ConfirmPaymentRequest, opts ...CallOption) (resp *PaymentStatusResponse, err error) {
+	err = g.mw(ctx, OperationConfirmPayment, func(ctx context.Context) error {
+		resp, err = g.next.ConfirmPayment(ctx, paymentID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) ConfirmPaymentByRequest(ctx context.Context, requestID string, req *ConfirmPaymentRequest, opts ...CallOption) (resp *PaymentStatusResponse, err error) {
+	err = g.mw(ctx, OperationConfirmPaymentByRequest, func(ctx context.Context) error {
+		resp, err = g.next.ConfirmPaymentByRequest(ctx, requestID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) RefundPayment(ctx context.Context, paymentID string, req *CreateRefundRequest, opts ...CallOption) (resp *Refund, err error) {
+	err = g.mw(ctx, OperationRefundPayment, func(ctx context.Context) error {
+		resp, err = g.next.RefundPayment(ctx, paymentID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) RefundPaymentByRequest(ctx context.Context, requestID string, req *CreateRefundRequest, opts ...CallOption) (resp *Refund, err error) {
+	err = g.mw(ctx, OperationRefundPaymentByRequest, func(ctx context.Context) error {
+		resp, err = g.next.RefundPaymentByRequest(ctx, requestID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) CreateTransfer(ctx context.Context, req *CreateTransferRequest, opts ...CallOption) (resp *Transfer, err error) {
+	err = g.mw(ctx, OperationCreateTransfer, func(ctx context.Context) error {
+		resp, err = g.next.CreateTransfer(ctx, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) GetTransferStatus(ctx context.Context, transferID string, opts ...CallOption) (resp *Transfer, err error) {
+	err = g.mw(ctx, OperationGetTransferStatus, func(ctx context.Context) error {
+		resp, err = g.next.GetTransferStatus(ctx, transferID, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) GetTransferStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (resp *Transfer, err error) {
+	err = g.mw(ctx, OperationGetTransferStatusByRequest, func(ctx context.Context) error {
+		resp, err = g.next.GetTransferStatusByRequest(ctx, requestID, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) CancelTransfer(ctx context.Context, transferID string, req *CancelTransferRequest, opts ...CallOption) (resp *Transfer, err error) {
+	err = g.mw(ctx, OperationCancelTransfer, func(ctx context.Context) error {
+		resp, err = g.next.CancelTransfer(ctx, transferID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (g *wrappedGateway) CancelTransferByRequest(ctx context.Context, requestID string, req *CancelTransferRequest, opts ...CallOption) (resp *Transfer, err error) {
+	err = g.mw(ctx, OperationCancelTransferByRequest, func(ctx context.Context) error {
+		resp, err = g.next.CancelTransferByRequest(ctx, requestID, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// LoggingMiddleware logs every call with its duration and error.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "qi call failed",
+				"operation", operation, "duration", time.Since(start), "error", err)
+			return err
+		}
+		logger.DebugContext(ctx, "qi call",
+			"operation", operation, "duration", time.Since(start))
+		return nil
+	}
+}
+
+// MetricsRecorder receives the outcome of every call.
+type MetricsRecorder interface {
+	ObserveCall(operation string, duration time.Duration, err error)
+}
+
+// MetricsMiddleware reports every call to recorder.
+func MetricsMiddleware(recorder MetricsRecorder) Middleware {
+	return func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		recorder.ObserveCall(operation, time.Since(start), err)
+		return err
+	}
+}
+
+// DefaultRetryBackoff is the default delay before the first retry.
+const DefaultRetryBackoff = 500 * time.Millisecond
+
+// RetryPolicy configures the retries of a Client, set with WithRetryPolicy or
+// WithRetry, and of RetryMiddleware.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed call is repeated.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled after each
+	// attempt. Zero uses DefaultRetryBackoff.
+	Backoff time.Duration
+	// RetryNonIdempotent also retries calls that change a payment without a
+	// way for the gateway to recognize the repeated request. A create,
+	// cancel, confirm or refund call whose first attempt reached the gateway
+	// may then be carried out twice.
+	RetryNonIdempotent bool
+}
+
+// RetryMiddleware repeats calls that failed with a transport error or a
+// retryable APIError. It only sees the operation, not the request, so it
+// repeats status lookups only, unless RetryNonIdempotent is set: a create,
+// cancel, confirm or refund call is only safe to retry with a requestId,
+// which the gateway rejects when reused, and the requestId of cancels,
+// confirmations and refunds is optional.
+//
+// The middleware repeats whole calls, and each call to a *Client also runs
+// the client's own retry policy, so the attempts multiply: a middleware
+// MaxRetries of 2 around a client MaxRetries of 3 makes up to 12 requests.
+// Use one of them. The client's retries also honor Retry-After and pass each
+// attempt through the rate limiter and circuit breaker, so the middleware is
+// meant for PaymentGateway implementations other than *Client.
+// GetPaymentStatuses sets its lookups' policy with WithRetry, replacing the
+// client's.
+func RetryMiddleware(policy RetryPolicy) Middleware {
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	return func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		retry := policy.RetryNonIdempotent || isLookup(operation)
+		for attempt := 0; ; attempt++ {
+			err := call(ctx)
+			if err == nil || !retry || attempt >= policy.MaxRetries || !isRetryable(ctx, err) {
+				return err
+			}
+			if err := sleep(ctx, backoff<<attempt); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isLookup returns true for the operations that only read the gateway state.
+func isLookup(operation string) bool {
+	switch operation {
+	case OperationGetPaymentStatus, OperationGetPaymentStatusByRequest,
+		OperationGetTransferStatus, OperationGetTransferStatusByRequest:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+	"github.com/BynxDev/qi/qimock"
+)
+
+type recordedCall struct {
+	operation string
+	err       error
+}
+
+type metrics []recordedCall
+
+func (m *metrics) ObserveCall(operation string, duration time.Duration, err error) {
+	*m = append(*m, recordedCall{operation, err})
+}
+
+func TestWrapGateway(t *testing.T) {
+	attempts := 0
+	mock := &qimock.Gateway{
+		GetPaymentStatusFunc: func(ctx context.Context, paymentID string) (*qi.PaymentStatusResponse, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, &qi.APIError{StatusCode: 503, Message: "unavailable"}
+			}
+			return &qi.PaymentStatusResponse{PaymentID: paymentID, Status: qi.PaymentStatusSuccess}, nil
+		},
+	}
+
+	var m metrics
+	gw := qi.WrapGateway(mock,
+		qi.MetricsMiddleware(&m),
+		qi.RetryMiddleware(qi.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}),
+	)
+
+	status, err := gw.GetPaymentStatus(context.Background(), "test-payment-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != qi.PaymentStatusSuccess {
+		t.Errorf("expected status SUCCESS, got %s", status.Status)
+	}
+	if calls := mock.CallsTo(qi.OperationGetPaymentStatus); len(calls) != 2 {
+		t.Errorf("expected 2 calls, got %d", len(calls))
+	}
+	if len(m) != 1 || m[0].operation != qi.OperationGetPaymentStatus || m[0].err != nil {
+		t.Errorf("expected one successful call in metrics, got %+v", m)
+	}
+
+	_, err = gw.CreatePayment(context.Background(), &qi.CreatePaymentRequest{RequestID: "r"})
+	if !errors.Is(err, qimock.ErrNotImplemented) {
+		t.Errorf("expected ErrNotImplemented, got %v", err)
+	}
+	if calls := mock.CallsTo(qi.OperationCreatePayment); len(calls) != 1 {
+		t.Errorf("expected non-retryable error not to be retried, got %d calls", len(calls))
+	}
+}
+
+func TestRetryMiddlewareNonIdempotent(t *testing.T) {
+	unavailable := &qi.APIError{StatusCode: 503, Message: "unavailable"}
+	mock := &qimock.Gateway{
+		CancelPaymentFunc: func(ctx context.Context, paymentID string, req *qi.CancelPaymentRequest) (*qi.PaymentCancelResponse, error) {
+			return nil, unavailable
+		},
+	}
+	policy := qi.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+	gw := qi.WrapGateway(mock, qi.RetryMiddleware(policy))
+	if _, err := gw.CancelPayment(context.Background(), "p-1", &qi.CancelPaymentRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls := mock.CallsTo(qi.OperationCancelPayment); len(calls) != 1 {
+		t.Errorf("expected cancel not to be retried, got %d calls", len(calls))
+	}
+
+	policy.RetryNonIdempotent = true
+	gw = qi.WrapGateway(mock, qi.RetryMiddleware(policy))
+	if _, err := gw.CancelPayment(context.Background(), "p-1", &qi.CancelPaymentRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls := mock.CallsTo(qi.OperationCancelPayment); len(calls) != 4 {
+		t.Errorf("expected cancel to be retried twice with RetryNonIdempotent, got %d calls", len(calls)-1)
+	}
+}
+
+func TestRetryMiddlewareAroundClient(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithRetryPolicy(qi.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}),
+	)
+	gw := qi.WrapGateway(client, qi.RetryMiddleware(qi.RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond}))
+
+	if _, err := gw.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error")
+	}
+	// Each of the 2 middleware attempts makes 3 client attempts.
+	if requests != 6 {
+		t.Errorf("expected the retries to multiply to 6 requests, got %d", requests)
+	}
+}
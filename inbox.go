@@ -0,0 +1,521 @@
+package qi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInboxWorkers is the default number of InboxWorker goroutines.
+	DefaultInboxWorkers = 4
+	// DefaultInboxMaxAttempts is the default number of processing attempts
+	// before an entry is moved to the dead-letter store.
+	DefaultInboxMaxAttempts = 10
+	// DefaultInboxPollInterval is the default delay between polls of an
+	// empty inbox.
+	DefaultInboxPollInterval = time.Second
+)
+
+// ErrInboxEntryNotFound is returned when an inbox or dead-letter entry does
+// not exist.
+var ErrInboxEntryNotFound = errors.New("qi: inbox entry not found")
+
+// InboxEntry is a verified notification persisted for later processing.
+type InboxEntry struct {
+	ID          string      `json:"id"`
+	Body        []byte      `json:"body"`
+	Header      http.Header `json:"header"` // only the inboxHeaders
+	ReceivedAt  time.Time   `json:"receivedAt"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt time.Time   `json:"nextAttempt"`
+	LastError   string      `json:"lastError,omitempty"`
+}
+
+// Inbox persists notifications until they have been processed.
+type Inbox interface {
+	// Put stores a new entry.
+	Put(ctx context.Context, entry *InboxEntry) error
+	// Claim returns an entry that is due for processing at now and that is
+	// not claimed by another worker, or nil if there is none.
+	Claim(ctx context.Context, now time.Time) (*InboxEntry, error)
+	// Ack deletes a processed entry.
+	Ack(ctx context.Context, id string) error
+	// Retry stores the updated attempt state of an entry and releases it.
+	Retry(ctx context.Context, entry *InboxEntry) error
+}
+
+// DeadLetterStore keeps entries that could not be processed.
+type DeadLetterStore interface {
+	// Put stores an entry.
+	Put(ctx context.Context, entry *InboxEntry) error
+	// Get returns an entry by ID.
+	Get(ctx context.Context, id string) (*InboxEntry, error)
+	// List returns all entries.
+	List(ctx context.Context) ([]*InboxEntry, error)
+	// Delete removes an entry.
+	Delete(ctx context.Context, id string) error
+}
+
+// ReplayDeadLetter moves an entry from the dead-letter store back to the
+// inbox, resetting its attempts.
+func ReplayDeadLetter(ctx context.Context, dead DeadLetterStore, inbox Inbox, id string) error {
+	entry, err := dead.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	entry.Attempts = 0
+	entry.NextAttempt = time.Time{}
+	entry.LastError = ""
+	if err := inbox.Put(ctx, entry); err != nil {
+		return err
+	}
+	return dead.Delete(ctx, id)
+}
+
+// WithInbox makes the handler persist verified notifications to inbox and
+// respond with 200 immediately. The notifications are passed to the
+// NotificationFunc by an InboxWorker.
+func WithInbox(inbox Inbox) NotificationOption {
+	return func(h *NotificationHandler) {
+		h.inbox = inbox
+	}
+}
+
+// inboxHeaders are the request headers kept with an inbox entry. Other
+// headers, such as Authorization or Cookie, may carry credentials and are not
+// needed to process the notification.
+var inboxHeaders = []string{"Content-Type", "X-Signature"}
+
+// enqueue stores a verified notification in the inbox.
+func (h *NotificationHandler) enqueue(ctx context.Context, body []byte, header http.Header) error {
+	id, err := newInboxID()
+	if err != nil {
+		return err
+	}
+
+	kept := make(http.Header, len(inboxHeaders))
+	for _, name := range inboxHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			kept[name] = append([]string(nil), values...)
+		}
+	}
+
+	now := time.Now()
+	return h.inbox.Put(ctx, &InboxEntry{
+		ID:          id,
+		Body:        body,
+		Header:      kept,
+		ReceivedAt:  now,
+		NextAttempt: now,
+	})
+}
+
+// Process decodes an inbox entry and dispatches it like a notification
+// received directly.
+func (h *NotificationHandler) Process(ctx context.Context, entry *InboxEntry) error {
+	var payment Payment
+	if err := json.Unmarshal(entry.Body, &payment); err != nil {
+		return fmt.Errorf("failed to unmarshal notification: %w", err)
+	}
+	return h.dispatch(ctx, &payment)
+}
+
+func newInboxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate inbox ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// InboxWorker processes inbox entries with a pool of goroutines, retrying
+// failed entries with exponential backoff.
+type InboxWorker struct {
+	handler      *NotificationHandler
+	inbox        Inbox
+	dead         DeadLetterStore
+	workers      int
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+}
+
+// InboxWorkerOption is a function that configures an InboxWorker.
+type InboxWorkerOption func(*InboxWorker)
+
+// WithInboxWorkers sets the number of goroutines processing entries.
+func WithInboxWorkers(n int) InboxWorkerOption {
+	return func(w *InboxWorker) {
+		if n > 0 {
+			w.workers = n
+		}
+	}
+}
+
+// WithInboxMaxAttempts sets the number of attempts after which an entry is
+// moved to the dead-letter store.
+func WithInboxMaxAttempts(n int) InboxWorkerOption {
+	return func(w *InboxWorker) {
+		if n > 0 {
+			w.maxAttempts = n
+		}
+	}
+}
+
+// WithInboxBackoff sets the delay before the first retry and the maximum
+// delay between retries.
+func WithInboxBackoff(base, max time.Duration) InboxWorkerOption {
+	return func(w *InboxWorker) {
+		w.backoff = base
+		w.maxBackoff = max
+	}
+}
+
+// WithInboxPollInterval sets how often an empty inbox is polled.
+func WithInboxPollInterval(d time.Duration) InboxWorkerOption {
+	return func(w *InboxWorker) {
+		w.pollInterval = d
+	}
+}
+
+// NewInboxWorker creates a new InboxWorker passing entries from inbox to
+// handler and moving entries that keep failing to dead.
+func NewInboxWorker(handler *NotificationHandler, inbox Inbox, dead DeadLetterStore, opts ...InboxWorkerOption) *InboxWorker {
+	w := &InboxWorker{
+		handler:      handler,
+		inbox:        inbox,
+		dead:         dead,
+		workers:      DefaultInboxWorkers,
+		maxAttempts:  DefaultInboxMaxAttempts,
+		backoff:      DefaultRetryBackoff,
+		maxBackoff:   time.Hour,
+		pollInterval: DefaultInboxPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Run processes entries until ctx is done.
+func (w *InboxWorker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				processed, err := w.ProcessNext(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil || !processed {
+					if sleep(ctx, w.pollInterval) != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// ProcessNext claims and processes a single entry. It returns false if no
+// entry was due.
+func (w *InboxWorker) ProcessNext(ctx context.Context) (bool, error) {
+	entry, err := w.inbox.Claim(ctx, time.Now())
+	if err != nil || entry == nil {
+		return false, err
+	}
+
+	procErr := w.handler.Process(ctx, entry)
+	if procErr == nil {
+		return true, w.inbox.Ack(ctx, entry.ID)
+	}
+	if ctx.Err() != nil {
+		// Failures caused by shutting down are not attempts, so the entry is
+		// only released.
+		return true, w.inbox.Retry(context.WithoutCancel(ctx), entry)
+	}
+
+	entry.Attempts++
+	entry.LastError = procErr.Error()
+	if entry.Attempts >= w.maxAttempts {
+		if err := w.dead.Put(ctx, entry); err != nil {
+			entry.NextAttempt = time.Now().Add(w.maxBackoff)
+			if retryErr := w.inbox.Retry(ctx, entry); retryErr != nil {
+				return true, retryErr
+			}
+			return true, err
+		}
+		return true, w.inbox.Ack(ctx, entry.ID)
+	}
+
+	delay := w.backoff << (entry.Attempts - 1)
+	if delay > w.maxBackoff || delay <= 0 {
+		delay = w.maxBackoff
+	}
+	entry.NextAttempt = time.Now().Add(delay)
+	return true, w.inbox.Retry(ctx, entry)
+}
+
+// fileEntryStore stores entries as one JSON file per entry in a directory.
+type fileEntryStore struct {
+	dir string
+}
+
+func (s fileEntryStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s fileEntryStore) write(entry *InboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inbox entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(entry.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	return nil
+}
+
+func (s fileEntryStore) read(id string) (*InboxEntry, error) {
+	if !validEntryID(id) {
+		return nil, ErrInboxEntryNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrInboxEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inbox entry: %w", err)
+	}
+
+	var entry InboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inbox entry: %w", err)
+	}
+	return &entry, nil
+}
+
+func (s fileEntryStore) list() ([]*InboxEntry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox entries: %w", err)
+	}
+
+	var entries []*InboxEntry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		entry, err := s.read(strings.TrimSuffix(name, ".json"))
+		if errors.Is(err, ErrInboxEntryNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ReceivedAt.Before(entries[j].ReceivedAt) })
+	return entries, nil
+}
+
+func (s fileEntryStore) remove(id string) error {
+	if !validEntryID(id) {
+		return ErrInboxEntryNotFound
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete inbox entry: %w", err)
+	}
+	return nil
+}
+
+// validEntryID rejects IDs that would resolve outside the store directory.
+func validEntryID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, ".") && !strings.ContainsAny(id, `/\`)
+}
+
+// FileInbox is an Inbox storing entries as files in a directory. The entries
+// are indexed in memory when the inbox is created, and claims are held in
+// memory too, so a directory must only be used by a single FileInbox.
+type FileInbox struct {
+	store   fileEntryStore
+	mu      sync.Mutex
+	entries map[string]*fileInboxItem
+}
+
+// fileInboxItem is the index entry of a stored InboxEntry.
+type fileInboxItem struct {
+	receivedAt  time.Time
+	nextAttempt time.Time
+	claimed     bool
+}
+
+// NewFileInbox creates a new FileInbox in dir, creating it if necessary.
+func NewFileInbox(dir string) (*FileInbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create inbox directory: %w", err)
+	}
+
+	b := &FileInbox{
+		store:   fileEntryStore{dir: dir},
+		entries: make(map[string]*fileInboxItem),
+	}
+	entries, err := b.store.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		b.index(entry)
+	}
+	return b, nil
+}
+
+func (b *FileInbox) index(entry *InboxEntry) {
+	b.entries[entry.ID] = &fileInboxItem{receivedAt: entry.ReceivedAt, nextAttempt: entry.NextAttempt}
+}
+
+// Put implements the Inbox interface.
+func (b *FileInbox) Put(ctx context.Context, entry *InboxEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.store.write(entry); err != nil {
+		return err
+	}
+	b.index(entry)
+	return nil
+}
+
+// Claim implements the Inbox interface. Only the claimed entry is read.
+func (b *FileInbox) Claim(ctx context.Context, now time.Time) (*InboxEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		var id string
+		var next *fileInboxItem
+		for itemID, item := range b.entries {
+			if item.claimed || item.nextAttempt.After(now) {
+				continue
+			}
+			if next == nil || item.receivedAt.Before(next.receivedAt) {
+				id, next = itemID, item
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+
+		entry, err := b.store.read(id)
+		if errors.Is(err, ErrInboxEntryNotFound) {
+			delete(b.entries, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		next.claimed = true
+		return entry, nil
+	}
+}
+
+// Ack implements the Inbox interface.
+func (b *FileInbox) Ack(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, id)
+	return b.store.remove(id)
+}
+
+// Retry implements the Inbox interface.
+func (b *FileInbox) Retry(ctx context.Context, entry *InboxEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if item, ok := b.entries[entry.ID]; ok {
+		item.claimed = false
+	}
+	if err := b.store.write(entry); err != nil {
+		return err
+	}
+	b.index(entry)
+	return nil
+}
+
+// FileDeadLetterStore is a DeadLetterStore storing entries as files in a
+// directory.
+type FileDeadLetterStore struct {
+	store fileEntryStore
+}
+
+// NewFileDeadLetterStore creates a new FileDeadLetterStore in dir, creating
+// it if necessary.
+func NewFileDeadLetterStore(dir string) (*FileDeadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	return &FileDeadLetterStore{store: fileEntryStore{dir: dir}}, nil
+}
+
+// Put implements the DeadLetterStore interface.
+func (s *FileDeadLetterStore) Put(ctx context.Context, entry *InboxEntry) error {
+	return s.store.write(entry)
+}
+
+// Get implements the DeadLetterStore interface.
+func (s *FileDeadLetterStore) Get(ctx context.Context, id string) (*InboxEntry, error) {
+	return s.store.read(id)
+}
+
+// List implements the DeadLetterStore interface.
+func (s *FileDeadLetterStore) List(ctx context.Context) ([]*InboxEntry, error) {
+	return s.store.list()
+}
+
+// Delete implements the DeadLetterStore interface.
+func (s *FileDeadLetterStore) Delete(ctx context.Context, id string) error {
+	return s.store.remove(id)
+}
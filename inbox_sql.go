@@ -0,0 +1,231 @@
+package qi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultInboxClaimTimeout is how long an SQLInbox entry stays claimed
+// before another worker may claim it again, unless set with
+// WithClaimTimeout.
+const DefaultInboxClaimTimeout = 5 * time.Minute
+
+// sqlInboxColumns are the columns shared by the inbox and dead-letter tables.
+const sqlInboxColumns = "id, body, header, received_at, attempts, next_attempt, last_error"
+
+// SQLInbox is an Inbox backed by a database/sql table created as:
+//
+//	CREATE TABLE qi_notification_inbox (
+//		id            VARCHAR(32) PRIMARY KEY,
+//		body          BLOB        NOT NULL,
+//		header        TEXT        NOT NULL,
+//		received_at   TIMESTAMP   NOT NULL,
+//		attempts      INTEGER     NOT NULL,
+//		next_attempt  TIMESTAMP   NOT NULL,
+//		last_error    TEXT        NOT NULL,
+//		claimed_until TIMESTAMP
+//	);
+//
+// Entries are claimed with a lease, so several processes may share a table.
+type SQLInbox struct {
+	db           *sql.DB
+	table        string
+	placeholder  Placeholder
+	claimTimeout time.Duration
+}
+
+// SQLInboxOption is a function that configures an SQLInbox.
+type SQLInboxOption func(*SQLInbox)
+
+// WithClaimTimeout sets how long a claimed entry stays claimed before another
+// worker may claim it again. It should exceed the time the NotificationFunc
+// takes to process an entry. The default is DefaultInboxClaimTimeout.
+func WithClaimTimeout(d time.Duration) SQLInboxOption {
+	return func(b *SQLInbox) {
+		if d > 0 {
+			b.claimTimeout = d
+		}
+	}
+}
+
+// NewSQLInbox creates a new SQLInbox using the given table.
+func NewSQLInbox(db *sql.DB, table string, placeholder Placeholder, opts ...SQLInboxOption) *SQLInbox {
+	b := &SQLInbox{
+		db:           db,
+		table:        table,
+		placeholder:  placeholder,
+		claimTimeout: DefaultInboxClaimTimeout,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Put implements the Inbox interface.
+func (b *SQLInbox) Put(ctx context.Context, entry *InboxEntry) error {
+	return insertSQLEntry(ctx, b.db, b.table, b.placeholder, entry)
+}
+
+// Claim implements the Inbox interface.
+func (b *SQLInbox) Claim(ctx context.Context, now time.Time) (*InboxEntry, error) {
+	now = now.UTC()
+	p := b.placeholder
+
+	query := fmt.Sprintf(`SELECT id FROM %s
+		WHERE next_attempt <= %s AND (claimed_until IS NULL OR claimed_until < %s)
+		ORDER BY received_at LIMIT 10`, b.table, p(1), p(2))
+	rows, err := b.db.QueryContext(ctx, query, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inbox: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan inbox: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query inbox: %w", err)
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET claimed_until = %s
+		WHERE id = %s AND (claimed_until IS NULL OR claimed_until < %s)`, b.table, p(1), p(2), p(3))
+	for _, id := range ids {
+		res, err := b.db.ExecContext(ctx, update, now.Add(b.claimTimeout), id, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim inbox entry: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			continue
+		}
+		return getSQLEntry(ctx, b.db, b.table, b.placeholder, id)
+	}
+	return nil, nil
+}
+
+// Ack implements the Inbox interface.
+func (b *SQLInbox) Ack(ctx context.Context, id string) error {
+	return deleteSQLEntry(ctx, b.db, b.table, b.placeholder, id)
+}
+
+// Retry implements the Inbox interface.
+func (b *SQLInbox) Retry(ctx context.Context, entry *InboxEntry) error {
+	p := b.placeholder
+	query := fmt.Sprintf(`UPDATE %s SET attempts = %s, next_attempt = %s, last_error = %s, claimed_until = NULL
+		WHERE id = %s`, b.table, p(1), p(2), p(3), p(4))
+	_, err := b.db.ExecContext(ctx, query, entry.Attempts, entry.NextAttempt.UTC(), entry.LastError, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update inbox entry: %w", err)
+	}
+	return nil
+}
+
+// SQLDeadLetterStore is a DeadLetterStore backed by a database/sql table with
+// the same columns as the SQLInbox table, except claimed_until.
+type SQLDeadLetterStore struct {
+	db          *sql.DB
+	table       string
+	placeholder Placeholder
+}
+
+// NewSQLDeadLetterStore creates a new SQLDeadLetterStore using the given table.
+func NewSQLDeadLetterStore(db *sql.DB, table string, placeholder Placeholder) *SQLDeadLetterStore {
+	return &SQLDeadLetterStore{db: db, table: table, placeholder: placeholder}
+}
+
+// Put implements the DeadLetterStore interface.
+func (s *SQLDeadLetterStore) Put(ctx context.Context, entry *InboxEntry) error {
+	return insertSQLEntry(ctx, s.db, s.table, s.placeholder, entry)
+}
+
+// Get implements the DeadLetterStore interface.
+func (s *SQLDeadLetterStore) Get(ctx context.Context, id string) (*InboxEntry, error) {
+	return getSQLEntry(ctx, s.db, s.table, s.placeholder, id)
+}
+
+// List implements the DeadLetterStore interface.
+func (s *SQLDeadLetterStore) List(ctx context.Context) ([]*InboxEntry, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY received_at", sqlInboxColumns, s.table)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead-letter store: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*InboxEntry
+	for rows.Next() {
+		entry, err := scanSQLEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Delete implements the DeadLetterStore interface.
+func (s *SQLDeadLetterStore) Delete(ctx context.Context, id string) error {
+	return deleteSQLEntry(ctx, s.db, s.table, s.placeholder, id)
+}
+
+func insertSQLEntry(ctx context.Context, db *sql.DB, table string, p Placeholder, entry *InboxEntry) error {
+	header, err := json.Marshal(entry.Header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inbox entry header: %w", err)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s, %s, %s, %s, %s, %s, %s)",
+		table, sqlInboxColumns, p(1), p(2), p(3), p(4), p(5), p(6), p(7))
+	_, err = db.ExecContext(ctx, query, entry.ID, entry.Body, string(header),
+		entry.ReceivedAt.UTC(), entry.Attempts, entry.NextAttempt.UTC(), entry.LastError)
+	if err != nil {
+		return fmt.Errorf("failed to insert inbox entry: %w", err)
+	}
+	return nil
+}
+
+func getSQLEntry(ctx context.Context, db *sql.DB, table string, p Placeholder, id string) (*InboxEntry, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = %s", sqlInboxColumns, table, p(1))
+	entry, err := scanSQLEntry(db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInboxEntryNotFound
+	}
+	return entry, err
+}
+
+func deleteSQLEntry(ctx context.Context, db *sql.DB, table string, p Placeholder, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = %s", table, p(1))
+	if _, err := db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete inbox entry: %w", err)
+	}
+	return nil
+}
+
+func scanSQLEntry(row interface{ Scan(...interface{}) error }) (*InboxEntry, error) {
+	var entry InboxEntry
+	var header string
+	err := row.Scan(&entry.ID, &entry.Body, &header, &entry.ReceivedAt,
+		&entry.Attempts, &entry.NextAttempt, &entry.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan inbox entry: %w", err)
+	}
+
+	entry.Header = http.Header{}
+	if err := json.Unmarshal([]byte(header), &entry.Header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inbox entry header: %w", err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,147 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestSQLInbox(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB(t, map[string][]string{"qi_notification_inbox": {"id"}})
+	inbox := qi.NewSQLInbox(db, "qi_notification_inbox", qi.DollarPlaceholder)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, id := range []string{"e2", "e1"} {
+		if err := inbox.Put(ctx, &qi.InboxEntry{
+			ID:          id,
+			Body:        []byte(`{"paymentId":"p1"}`),
+			Header:      http.Header{"X-Signature": {"sig"}},
+			ReceivedAt:  now.Add(time.Duration(-i) * time.Second),
+			NextAttempt: now,
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	entry, err := inbox.Claim(ctx, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry == nil || entry.ID != "e1" {
+		t.Fatalf("expected the oldest entry to be claimed, got %+v", entry)
+	}
+	if string(entry.Body) != `{"paymentId":"p1"}` || entry.Header.Get("X-Signature") != "sig" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	// A claimed entry is leased until the claim timeout.
+	second, err := inbox.Claim(ctx, now)
+	if err != nil || second == nil || second.ID != "e2" {
+		t.Fatalf("expected e2 to be claimed, got %+v, %v", second, err)
+	}
+	if none, err := inbox.Claim(ctx, now); err != nil || none != nil {
+		t.Fatalf("expected no claimable entry, got %+v, %v", none, err)
+	}
+	if expired, err := inbox.Claim(ctx, now.Add(qi.DefaultInboxClaimTimeout+time.Second)); err != nil || expired == nil {
+		t.Fatalf("expected an expired lease to be claimed again, got %+v, %v", expired, err)
+	}
+
+	entry.Attempts = 1
+	entry.LastError = "temporary failure"
+	entry.NextAttempt = now.Add(time.Minute)
+	if err := inbox.Retry(ctx, entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if none, _ := inbox.Claim(ctx, now); none != nil {
+		t.Fatalf("expected a retried entry not to be due, got %+v", none)
+	}
+	retried, err := inbox.Claim(ctx, now.Add(time.Minute))
+	if err != nil || retried == nil || retried.ID != "e1" {
+		t.Fatalf("expected e1 to be due, got %+v, %v", retried, err)
+	}
+	if retried.Attempts != 1 || retried.LastError != "temporary failure" {
+		t.Errorf("expected the attempt state to be stored, got %+v", retried)
+	}
+
+	if err := inbox.Ack(ctx, "e1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if none, _ := inbox.Claim(ctx, now.Add(time.Hour)); none != nil && none.ID == "e1" {
+		t.Error("expected acknowledged entry to be deleted")
+	}
+}
+
+func TestSQLInboxClaimTimeout(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB(t, map[string][]string{"qi_notification_inbox": {"id"}})
+	inbox := qi.NewSQLInbox(db, "qi_notification_inbox", qi.DollarPlaceholder, qi.WithClaimTimeout(time.Minute))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := inbox.Put(ctx, &qi.InboxEntry{ID: "e1", Body: []byte("{}"), Header: http.Header{}, ReceivedAt: now, NextAttempt: now}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry, err := inbox.Claim(ctx, now); err != nil || entry == nil {
+		t.Fatalf("expected e1 to be claimed, got %+v, %v", entry, err)
+	}
+	if none, err := inbox.Claim(ctx, now.Add(30*time.Second)); err != nil || none != nil {
+		t.Fatalf("expected e1 to stay claimed, got %+v, %v", none, err)
+	}
+	if expired, err := inbox.Claim(ctx, now.Add(time.Minute+time.Second)); err != nil || expired == nil {
+		t.Fatalf("expected e1 to be claimed again after the claim timeout, got %+v, %v", expired, err)
+	}
+}
+
+func TestSQLDeadLetterStore(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB(t, map[string][]string{
+		"qi_notification_inbox": {"id"},
+		"qi_notification_dead":  {"id"},
+	})
+	dead := qi.NewSQLDeadLetterStore(db, "qi_notification_dead", qi.QuestionPlaceholder)
+	inbox := qi.NewSQLInbox(db, "qi_notification_inbox", qi.QuestionPlaceholder)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, id := range []string{"d2", "d1"} {
+		if err := dead.Put(ctx, &qi.InboxEntry{
+			ID:          id,
+			Body:        []byte("{}"),
+			Header:      http.Header{},
+			ReceivedAt:  now.Add(time.Duration(-i) * time.Second),
+			Attempts:    10,
+			NextAttempt: now,
+			LastError:   "permanent failure",
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	entries, err := dead.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "d1" || entries[0].LastError != "permanent failure" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if _, err := dead.Get(ctx, "missing"); !errors.Is(err, qi.ErrInboxEntryNotFound) {
+		t.Errorf("expected ErrInboxEntryNotFound, got %v", err)
+	}
+
+	if err := qi.ReplayDeadLetter(ctx, dead, inbox, "d1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := dead.Get(ctx, "d1"); !errors.Is(err, qi.ErrInboxEntryNotFound) {
+		t.Errorf("expected replayed entry to be deleted, got %v", err)
+	}
+	entry, err := inbox.Claim(ctx, time.Now())
+	if err != nil || entry == nil || entry.ID != "d1" {
+		t.Fatalf("expected replayed entry in the inbox, got %+v, %v", entry, err)
+	}
+	if entry.Attempts != 0 || entry.LastError != "" {
+		t.Errorf("expected attempts to be reset, got %+v", entry)
+	}
+}
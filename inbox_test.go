@@ -0,0 +1,183 @@
+package qi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestInboxWorker(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	dir := t.TempDir()
+
+	inbox, err := qi.NewFileInbox(filepath.Join(dir, "inbox"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dead, err := qi.NewFileDeadLetterStore(filepath.Join(dir, "dead"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fail := true
+	var dispatched int
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			if fail {
+				return errors.New("temporary failure")
+			}
+			dispatched++
+			return nil
+		},
+		qi.WithInbox(inbox),
+	)
+
+	body := notificationBody("p1", qi.PaymentStatusSuccess)
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	r.Header.Set("X-Signature", signNotification(t, key, body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Basic c2VjcmV0")
+	r.Header.Set("Cookie", "session=secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if dispatched != 0 {
+		t.Fatal("notification should not be processed inline")
+	}
+
+	worker := qi.NewInboxWorker(handler, inbox, dead,
+		qi.WithInboxMaxAttempts(2),
+		qi.WithInboxBackoff(time.Millisecond, time.Millisecond),
+	)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		if processed, err := worker.ProcessNext(ctx); err != nil || !processed {
+			t.Fatalf("attempt %d: processed=%v err=%v", i, processed, err)
+		}
+	}
+
+	entries, err := dead.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Attempts != 2 || entries[0].LastError != "temporary failure" {
+		t.Fatalf("expected one dead letter after 2 attempts, got %+v", entries)
+	}
+	if header := entries[0].Header; len(header) != 2 || header.Get("X-Signature") == "" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected only the signature and content type to be stored, got %v", header)
+	}
+	if processed, _ := worker.ProcessNext(ctx); processed {
+		t.Fatal("expected empty inbox")
+	}
+
+	fail = false
+	if err := qi.ReplayDeadLetter(ctx, dead, inbox, entries[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if processed, err := worker.ProcessNext(ctx); err != nil || !processed {
+		t.Fatalf("processed=%v err=%v", processed, err)
+	}
+	if dispatched != 1 {
+		t.Errorf("expected replayed notification to be dispatched, got %d", dispatched)
+	}
+	if entries, _ := dead.List(ctx); len(entries) != 0 {
+		t.Errorf("expected empty dead-letter store, got %d entries", len(entries))
+	}
+}
+
+func TestFileInboxClaim(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Now()
+
+	inbox, err := qi.NewFileInbox(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, id := range []string{"later", "first", "second"} {
+		entry := &qi.InboxEntry{ID: id, Body: []byte("{}"), ReceivedAt: now.Add(time.Duration(i) * time.Second), NextAttempt: now}
+		if id == "later" {
+			entry.NextAttempt = now.Add(time.Hour)
+		}
+		if err := inbox.Put(ctx, entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Entries are indexed when the inbox is opened, and only the claimed
+	// entry is read afterwards.
+	inbox, err = qi.NewFileInbox(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "later.json"), []byte("corrupt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"first", "second", ""} {
+		entry, err := inbox.Claim(ctx, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entry == nil && want != "" || entry != nil && entry.ID != want {
+			t.Fatalf("expected %q to be claimed, got %+v", want, entry)
+		}
+	}
+}
+
+func TestInboxWorkerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inbox, err := qi.NewFileInbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dead, err := qi.NewFileDeadLetterStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := newTestKey(t)
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			cancel()
+			return ctx.Err()
+		},
+	)
+	now := time.Now()
+	if err := inbox.Put(ctx, &qi.InboxEntry{
+		ID:          "e1",
+		Body:        notificationBody("p1", qi.PaymentStatusSuccess),
+		ReceivedAt:  now,
+		NextAttempt: now,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	worker := qi.NewInboxWorker(handler, inbox, dead, qi.WithInboxMaxAttempts(1))
+	if _, err := worker.ProcessNext(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if entries, _ := dead.List(context.Background()); len(entries) != 0 {
+		t.Errorf("expected no dead letters after shutdown, got %d", len(entries))
+	}
+	entry, err := inbox.Claim(context.Background(), time.Now())
+	if err != nil || entry == nil {
+		t.Fatalf("expected entry to be released, got %v, %v", entry, err)
+	}
+	if entry.Attempts != 0 {
+		t.Errorf("expected the attempt not to be counted, got %d", entry.Attempts)
+	}
+}
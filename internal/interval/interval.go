@@ -0,0 +1,50 @@
+// Package interval spaces out calls by a fixed interval.
+package interval
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces out calls to Wait so that at most perSecond calls proceed
+// each second.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter creates a Limiter. A perSecond of zero or less means no limit.
+func NewLimiter(perSecond float64) *Limiter {
+	l := &Limiter{}
+	if perSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return l
+}
+
+// Wait blocks until the call may proceed or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
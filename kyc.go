@@ -0,0 +1,202 @@
+package qi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// IdentificationType is the code of a customer's identity document.
+type IdentificationType string
+
+const (
+	IdentificationPassport         IdentificationType = "00"
+	IdentificationNationalID       IdentificationType = "01"
+	IdentificationDriversLicense   IdentificationType = "02"
+	IdentificationGovernmentIssued IdentificationType = "03"
+	IdentificationOther            IdentificationType = "04"
+)
+
+// IsKnown returns true if t is one of the documented identification types.
+func (t IdentificationType) IsKnown() bool {
+	switch t {
+	case IdentificationPassport, IdentificationNationalID, IdentificationDriversLicense,
+		IdentificationGovernmentIssued, IdentificationOther:
+		return true
+	}
+	return false
+}
+
+// FundSource is the code of the source of a customer's funds. The meaning of
+// each code depends on the card scheme.
+type FundSource string
+
+const (
+	FundSourceVisaCredit      FundSource = "01"
+	FundSourceVisaDebit       FundSource = "02"
+	FundSourceVisaPrepaid     FundSource = "03"
+	FundSourceVisaCash        FundSource = "04"
+	FundSourceVisaNonCash     FundSource = "05" // non-cash, non-credit other than a Visa card
+	FundSourceVisaOtherCredit FundSource = "06" // credit other than a Visa card
+
+	FundSourceMastercardCredit      FundSource = "01"
+	FundSourceMastercardDebit       FundSource = "02"
+	FundSourceMastercardPrepaid     FundSource = "03"
+	FundSourceMastercardDeposit     FundSource = "04"
+	FundSourceMastercardMobileMoney FundSource = "05"
+	FundSourceMastercardCash        FundSource = "06"
+)
+
+// IsKnown returns true if s is one of the documented fund source codes.
+func (s FundSource) IsKnown() bool {
+	return len(s) == 2 && s >= "01" && s <= "06"
+}
+
+// Province is the region code of a customer address. The API accepts any code
+// of up to MaxProvinceLength characters, and its own examples use gateway
+// codes such as "BGD" for Baghdad. The constants are the Iraqi governorate
+// codes of ISO 3166-2:IQ, without the IQ- prefix; call IsKnown to require
+// them.
+type Province string
+
+// MaxProvinceLength is the maximum length of a province code.
+const MaxProvinceLength = 10
+
+const (
+	ProvinceAnbar        Province = "AN"
+	ProvinceErbil        Province = "AR"
+	ProvinceBasra        Province = "BA"
+	ProvinceBabil        Province = "BB"
+	ProvinceBaghdad      Province = "BG"
+	ProvinceDuhok        Province = "DA"
+	ProvinceDiyala       Province = "DI"
+	ProvinceDhiQar       Province = "DQ"
+	ProvinceKarbala      Province = "KA"
+	ProvinceKirkuk       Province = "KI"
+	ProvinceMaysan       Province = "MA"
+	ProvinceMuthanna     Province = "MU"
+	ProvinceNajaf        Province = "NA"
+	ProvinceNinawa       Province = "NI"
+	ProvinceQadisiyah    Province = "QA"
+	ProvinceSalahAlDin   Province = "SD"
+	ProvinceSulaymaniyah Province = "SU"
+	ProvinceWasit        Province = "WA"
+)
+
+var provinceNames = map[Province]string{
+	ProvinceAnbar:        "Al Anbar",
+	ProvinceErbil:        "Erbil",
+	ProvinceBasra:        "Basra",
+	ProvinceBabil:        "Babil",
+	ProvinceBaghdad:      "Baghdad",
+	ProvinceDuhok:        "Duhok",
+	ProvinceDiyala:       "Diyala",
+	ProvinceDhiQar:       "Dhi Qar",
+	ProvinceKarbala:      "Karbala",
+	ProvinceKirkuk:       "Kirkuk",
+	ProvinceMaysan:       "Maysan",
+	ProvinceMuthanna:     "Al Muthanna",
+	ProvinceNajaf:        "Najaf",
+	ProvinceNinawa:       "Ninawa",
+	ProvinceQadisiyah:    "Al Qadisiyah",
+	ProvinceSalahAlDin:   "Salah al-Din",
+	ProvinceSulaymaniyah: "Sulaymaniyah",
+	ProvinceWasit:        "Wasit",
+}
+
+// IsKnown returns true if p is an ISO 3166-2:IQ governorate code. Validate
+// does not require it, so callers that only accept ISO codes check it
+// themselves.
+func (p Province) IsKnown() bool {
+	_, ok := provinceNames[p]
+	return ok
+}
+
+// Name returns the English name of the governorate, or an empty string if p
+// is unknown.
+func (p Province) Name() string {
+	return provinceNames[p]
+}
+
+// KYCFlow lists the customer fields that a payment flow requires. Fields are
+// the JSON names of CustomerInfo fields. Callers with the requirements of
+// their acquirer should define their own flows.
+type KYCFlow struct {
+	Name   string
+	Fields []string
+}
+
+var (
+	// KYCOCTRefund lists the recipient details commonly required by card
+	// schemes for refunds processed as an original credit transaction. The
+	// API specification does not list required fields, so this is an
+	// assumption rather than the gateway's rule.
+	KYCOCTRefund = KYCFlow{
+		Name: "OCT refund",
+		Fields: []string{
+			"firstName", "lastName", "countryCode",
+			"identificationType", "identificationNumber", "fundSource",
+		},
+	}
+
+	// KYCHighValuePayment lists the customer details typically required to
+	// identify the payer of a high-value payment. Like KYCOCTRefund, it is not
+	// taken from the API specification.
+	KYCHighValuePayment = KYCFlow{
+		Name: "high-value payment",
+		Fields: []string{
+			"firstName", "lastName", "phone", "birthDate", "nationality",
+			"address", "city", "countryCode",
+			"identificationType", "identificationNumber",
+			"identificationCountryCode", "identificationExpirationDate",
+		},
+	}
+)
+
+// MissingKYC returns the fields required by flow that are not set, in the
+// order they are listed in the flow. Fields that CustomerInfo does not have
+// are always reported as missing.
+func (c *CustomerInfo) MissingKYC(flow KYCFlow) []string {
+	var present map[string]bool
+	if c != nil {
+		present = setFields(reflect.ValueOf(*c))
+	}
+	var missing []string
+	for _, name := range flow.Fields {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// CheckKYC returns a ValidationError listing the fields required by flow that
+// are not set.
+func (c *CustomerInfo) CheckKYC(flow KYCFlow) error {
+	missing := c.MissingKYC(flow)
+	if len(missing) == 0 {
+		return nil
+	}
+	return &ValidationError{
+		Field:  "customerInfo",
+		Reason: fmt.Sprintf("missing %s required for %s", strings.Join(missing, ", "), flow.Name),
+	}
+}
+
+// setFields returns the JSON names of the non-zero fields of v.
+func setFields(v reflect.Value) map[string]bool {
+	set := make(map[string]bool)
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || v.Field(i).IsZero() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		set[name] = true
+	}
+	return set
+}
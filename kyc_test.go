@@ -0,0 +1,92 @@
+package qi_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestLookupCountry(t *testing.T) {
+	for _, code := range []string{"IQ", "IRQ"} {
+		info, ok := qi.LookupCountry(code)
+		if !ok || info.Alpha2 != qi.CountryIraq || info.Alpha3 != "IRQ" || info.Numeric != 368 {
+			t.Errorf("%s: unexpected entry %+v", code, info)
+		}
+	}
+	if info, ok := qi.LookupCountryNumeric(840); !ok || info.Alpha2 != "US" {
+		t.Errorf("expected numeric 840 to be US, got %+v", info)
+	}
+	if qi.Country("XX").IsKnown() {
+		t.Error("expected XX to be unknown")
+	}
+}
+
+func TestProvinceIsKnown(t *testing.T) {
+	if !qi.ProvinceBaghdad.IsKnown() || qi.ProvinceBaghdad.Name() != "Baghdad" {
+		t.Error("expected BG to be the ISO code of Baghdad")
+	}
+	if qi.Province("BGD").IsKnown() {
+		t.Error("expected the gateway code BGD not to be an ISO code")
+	}
+}
+
+func TestCustomerInfoValidateKYC(t *testing.T) {
+	tests := []struct {
+		info  qi.CustomerInfo
+		field string
+	}{
+		{qi.CustomerInfo{CountryCode: "IRQ", ProvinceCode: qi.ProvinceBaghdad, IdentificationType: qi.IdentificationPassport}, ""},
+		{qi.CustomerInfo{CountryCode: "US", ProvinceCode: "CA"}, ""},
+		{qi.CustomerInfo{Nationality: "XX"}, "customerInfo.nationality"},
+		{qi.CustomerInfo{CountryCode: qi.CountryIraq, ProvinceCode: "BGD"}, ""},
+		{qi.CustomerInfo{CountryCode: qi.CountryIraq, ProvinceCode: "BAGHDAD-CITY"}, "customerInfo.provinceCode"},
+		{qi.CustomerInfo{IdentificationType: "05"}, "customerInfo.identificationType"},
+		{qi.CustomerInfo{FundSource: "07"}, "customerInfo.fundSource"},
+	}
+
+	for _, tt := range tests {
+		err := tt.info.Validate()
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", tt.info, err)
+			}
+			continue
+		}
+		var validationErr *qi.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+			t.Errorf("%+v: expected %s validation error, got %v", tt.info, tt.field, err)
+		}
+	}
+}
+
+func TestMissingKYC(t *testing.T) {
+	info := &qi.CustomerInfo{
+		FirstName:          "John",
+		LastName:           "Doe",
+		IdentificationType: qi.IdentificationNationalID,
+		FundSource:         qi.FundSourceVisaDebit,
+	}
+
+	missing := info.MissingKYC(qi.KYCOCTRefund)
+	if !reflect.DeepEqual(missing, []string{"countryCode", "identificationNumber"}) {
+		t.Errorf("unexpected missing fields %v", missing)
+	}
+
+	var validationErr *qi.ValidationError
+	if err := info.CheckKYC(qi.KYCOCTRefund); !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+
+	info.CountryCode = qi.CountryIraq
+	info.IdentificationNumber = "123456789"
+	if err := info.CheckKYC(qi.KYCOCTRefund); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var none *qi.CustomerInfo
+	if got := none.MissingKYC(qi.KYCOCTRefund); len(got) != len(qi.KYCOCTRefund.Fields) {
+		t.Errorf("expected all fields missing for nil customer, got %v", got)
+	}
+}
@@ -0,0 +1,230 @@
+package qi
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxAdditionalInfo is the maximum number of additionalInfo properties
+// accepted by the API.
+const MaxAdditionalInfo = 10
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// EncodeAdditionalInfo encodes the fields of the struct v that have a qi tag
+// into additionalInfo properties, e.g.
+//
+//	type Metadata struct {
+//		OrderID  int64  `qi:"orderId"`
+//		TenantID string `qi:"tenantId,omitempty"`
+//	}
+//
+// Strings, booleans, numbers, time.Duration and types implementing
+// encoding.TextMarshaler are supported. Nil pointers and, with omitempty, zero
+// values are left out. Encoding fails if the result has more than
+// MaxAdditionalInfo properties.
+func EncodeAdditionalInfo(v interface{}) (map[string]string, error) {
+	rv, err := metadataStruct("encode", v)
+	if err != nil {
+		return nil, err
+	}
+
+	info := make(map[string]string)
+	for _, f := range metadataFields(rv.Type()) {
+		fv := rv.Field(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		s, err := encodeMetadataValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode additionalInfo key %q: %w", f.key, err)
+		}
+		info[f.key] = s
+	}
+	if len(info) > MaxAdditionalInfo {
+		return nil, &ValidationError{
+			Field:  "additionalInfo",
+			Reason: fmt.Sprintf("%d properties exceed the limit of %d", len(info), MaxAdditionalInfo),
+		}
+	}
+	return info, nil
+}
+
+// DecodeAdditionalInfo decodes additionalInfo properties into the fields of
+// the struct pointed to by v that have a qi tag. Fields whose key is missing
+// are left unchanged and properties without a matching field are ignored.
+func DecodeAdditionalInfo(info map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("failed to decode additionalInfo: %T is not a non-nil pointer", v)
+	}
+	rv, err := metadataStruct("decode", rv.Interface())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range metadataFields(rv.Type()) {
+		s, ok := info[f.key]
+		if !ok {
+			continue
+		}
+		fv := rv.Field(f.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if err := decodeMetadataValue(fv, s); err != nil {
+			return fmt.Errorf("failed to decode additionalInfo key %q: %w", f.key, err)
+		}
+	}
+	return nil
+}
+
+// DecodeAdditionalInfo decodes the additionalInfo of the payment into v. See
+// the DecodeAdditionalInfo function.
+func (p *Payment) DecodeAdditionalInfo(v interface{}) error {
+	return DecodeAdditionalInfo(p.AdditionalInfo, v)
+}
+
+// DecodeAdditionalInfo decodes the additionalInfo of the payment into v. See
+// the DecodeAdditionalInfo function.
+func (s *PaymentStatusResponse) DecodeAdditionalInfo(v interface{}) error {
+	return DecodeAdditionalInfo(s.AdditionalInfo, v)
+}
+
+// DecodeAdditionalInfo decodes the additionalInfo of the payment into v. See
+// the DecodeAdditionalInfo function.
+func (r *PaymentCancelResponse) DecodeAdditionalInfo(v interface{}) error {
+	return DecodeAdditionalInfo(r.AdditionalInfo, v)
+}
+
+// metadataStruct returns the struct value of v, dereferencing pointers.
+func metadataStruct(op string, v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("failed to %s additionalInfo: %T is not a struct", op, v)
+	}
+	return rv, nil
+}
+
+type metadataField struct {
+	index     int
+	key       string
+	omitempty bool
+}
+
+// metadataFields returns the fields of typ that have a qi tag.
+func metadataFields(typ reflect.Type) []metadataField {
+	var fields []metadataField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, ok := f.Tag.Lookup("qi")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		if key == "" {
+			key = f.Name
+		}
+		fields = append(fields, metadataField{index: i, key: key, omitempty: opts == "omitempty"})
+	}
+	return fields
+}
+
+func encodeMetadataValue(v reflect.Value) (string, error) {
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		// MarshalText has a pointer receiver. Fields of a struct passed by
+		// value are not addressable, so a copy is marshaled instead.
+		if !v.CanAddr() {
+			c := reflect.New(v.Type()).Elem()
+			c.Set(v)
+			v = c
+		}
+		b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func decodeMetadataValue(v reflect.Value, s string) error {
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
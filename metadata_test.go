@@ -0,0 +1,124 @@
+package qi_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+type orderMetadata struct {
+	OrderID   int64         `qi:"orderId"`
+	Tenant    string        `qi:"tenant"`
+	Express   bool          `qi:"express,omitempty"`
+	Discount  *float64      `qi:"discount"`
+	Hold      time.Duration `qi:"hold"`
+	CreatedAt time.Time     `qi:"createdAt"`
+	Internal  string
+}
+
+func TestAdditionalInfoRoundTrip(t *testing.T) {
+	discount := 12.5
+	in := orderMetadata{
+		OrderID:   1234,
+		Tenant:    "acme",
+		Discount:  &discount,
+		Hold:      90 * time.Second,
+		CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Internal:  "not encoded",
+	}
+
+	info, err := qi.EncodeAdditionalInfo(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"orderId":   "1234",
+		"tenant":    "acme",
+		"discount":  "12.5",
+		"hold":      "1m30s",
+		"createdAt": "2024-01-15T10:30:00Z",
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("unexpected additionalInfo %v", info)
+	}
+
+	// Decode from a webhook payload
+	data, _ := json.Marshal(map[string]interface{}{"paymentId": "p-1", "additionalInfo": info})
+	var payment qi.Payment
+	if err := json.Unmarshal(data, &payment); err != nil {
+		t.Fatal(err)
+	}
+	var out orderMetadata
+	if err := payment.DecodeAdditionalInfo(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	in.Internal = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+// sku marshals with a pointer receiver.
+type sku struct{ vendor, code string }
+
+func (s *sku) MarshalText() ([]byte, error) {
+	return []byte(s.vendor + "-" + s.code), nil
+}
+
+func (s *sku) UnmarshalText(text []byte) error {
+	s.vendor, s.code, _ = strings.Cut(string(text), "-")
+	return nil
+}
+
+func TestAdditionalInfoPointerMarshaler(t *testing.T) {
+	type itemMetadata struct {
+		SKU sku `qi:"sku"`
+	}
+	in := itemMetadata{SKU: sku{vendor: "acme", code: "42"}}
+
+	// Both addressable and non-addressable fields use the pointer method.
+	for _, v := range []interface{}{in, &in} {
+		info, err := qi.EncodeAdditionalInfo(v)
+		if err != nil {
+			t.Fatalf("%T: unexpected error: %v", v, err)
+		}
+		if info["sku"] != "acme-42" {
+			t.Errorf("%T: expected sku acme-42, got %v", v, info)
+		}
+	}
+
+	payment := qi.Payment{AdditionalInfo: map[string]string{"sku": "acme-42"}}
+	var out itemMetadata
+	if err := payment.DecodeAdditionalInfo(&out); err != nil || out != in {
+		t.Errorf("expected %+v, got %+v, %v", in, out, err)
+	}
+}
+
+func TestDecodeAdditionalInfoInvalid(t *testing.T) {
+	var out orderMetadata
+	err := qi.DecodeAdditionalInfo(map[string]string{"orderId": "abc"}, &out)
+	if err == nil {
+		t.Fatal("expected error for non-numeric orderId")
+	}
+
+	if err := qi.DecodeAdditionalInfo(nil, out); err == nil {
+		t.Error("expected error for non-pointer")
+	}
+}
+
+func TestEncodeAdditionalInfoLimit(t *testing.T) {
+	type wide struct {
+		A, B, C, D, E, F, G, H, I, J, K string `qi:""`
+	}
+
+	_, err := qi.EncodeAdditionalInfo(wide{})
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "additionalInfo" {
+		t.Errorf("expected additionalInfo validation error, got %v", err)
+	}
+}
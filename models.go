@@ -68,6 +68,56 @@ const (
 	PaymentStatusExpired                   PaymentStatus = "EXPIRED"
 )
 
+// IsTerminal returns true if the payment has reached a final status.
+func (s PaymentStatus) IsTerminal() bool {
+	return s == PaymentStatusSuccess || s.IsFailure()
+}
+
+// paymentStatusStages orders the non-terminal statuses by how far the payment
+// has progressed. Terminal statuses share the last stage.
+var paymentStatusStages = map[PaymentStatus]int{
+	PaymentStatusCreated:                   0,
+	PaymentStatusFormShowed:                1,
+	PaymentStatusThreeDSMethodCallRequired: 2,
+	PaymentStatusAuthenticationRequired:    3,
+	PaymentStatusAuthenticationStarted:     4,
+	PaymentStatusAuthenticated:             5,
+	PaymentStatusInitialized:               6,
+	PaymentStatusStarted:                   7,
+}
+
+// Precedes returns true if a payment in status s can still move to status t.
+// A status never precedes itself, and terminal statuses precede nothing.
+func (s PaymentStatus) Precedes(t PaymentStatus) bool {
+	if s.IsTerminal() || s == t {
+		return false
+	}
+	if t.IsTerminal() {
+		return true
+	}
+	from, ok := paymentStatusStages[s]
+	if !ok {
+		return false
+	}
+	to, ok := paymentStatusStages[t]
+	return ok && from < to
+}
+
+// IsKnown returns true if s is a status known to this version of the client.
+func (s PaymentStatus) IsKnown() bool {
+	_, ok := paymentStatusStages[s]
+	return ok || s.IsTerminal()
+}
+
+// IsFailure returns true if the payment has finished unsuccessfully.
+func (s PaymentStatus) IsFailure() bool {
+	switch s {
+	case PaymentStatusAuthenticationFailed, PaymentStatusFailed, PaymentStatusError, PaymentStatusExpired:
+		return true
+	}
+	return false
+}
+
 // RefundStatus represents the status of a refund.
 type RefundStatus string
 
@@ -77,6 +127,15 @@ const (
 	RefundStatusProcessing RefundStatus = "PROCESSING"
 )
 
+// IsKnown returns true if s is a status known to this version of the client.
+func (s RefundStatus) IsKnown() bool {
+	switch s {
+	case RefundStatusSuccess, RefundStatusFailed, RefundStatusProcessing:
+		return true
+	}
+	return false
+}
+
 // PaymentSystem represents the payment system type.
 type PaymentSystem string
 
@@ -85,13 +144,33 @@ const (
 	PaymentSystemMasterCard PaymentSystem = "MASTER_CARD"
 )
 
+// IsKnown returns true if s is a payment system known to this version of the
+// client.
+func (s PaymentSystem) IsKnown() bool {
+	return s == PaymentSystemVisa || s == PaymentSystemMasterCard
+}
+
 // PaymentType represents the type of payment.
 type PaymentType string
 
 const (
 	PaymentTypePaymentToken PaymentType = "PAYMENT_TOKEN"
+	// PaymentTypeCard is the paymentType of card payments in payment status
+	// responses. It is not part of the PaymentType enum of the API
+	// specification, which only lists the types of payment requests.
+	PaymentTypeCard PaymentType = "CARD"
 )
 
+// IsKnown returns true if t is a payment type known to this version of the
+// client.
+func (t PaymentType) IsKnown() bool {
+	switch t {
+	case PaymentTypePaymentToken, PaymentTypeCard:
+		return true
+	}
+	return false
+}
+
 // PaymentTokenType represents the type of payment token.
 type PaymentTokenType string
 
@@ -101,6 +180,16 @@ const (
 	PaymentTokenTypeUnauth   PaymentTokenType = "UNAUTH"
 )
 
+// IsKnown returns true if t is a token type known to this version of the
+// client.
+func (t PaymentTokenType) IsKnown() bool {
+	switch t {
+	case PaymentTokenTypeAuth, PaymentTokenTypeNonRecur, PaymentTokenTypeUnauth:
+		return true
+	}
+	return false
+}
+
 // ItemPaymentMethod represents the payment method for an item.
 type ItemPaymentMethod string
 
@@ -114,6 +203,18 @@ const (
 	ItemPaymentMethodCreditPayment  ItemPaymentMethod = "CREDIT_PAYMENT"
 )
 
+// IsKnown returns true if m is a payment method known to this version of the
+// client.
+func (m ItemPaymentMethod) IsKnown() bool {
+	switch m {
+	case ItemPaymentMethodFullPayment, ItemPaymentMethodFullPrepayment, ItemPaymentMethodPrepayment,
+		ItemPaymentMethodAdvance, ItemPaymentMethodPartialPayment, ItemPaymentMethodCredit,
+		ItemPaymentMethodCreditPayment:
+		return true
+	}
+	return false
+}
+
 // ItemPaymentObject represents the payment object type for an item.
 type ItemPaymentObject string
 
@@ -133,6 +234,19 @@ const (
 	ItemPaymentObjectAnother              ItemPaymentObject = "ANOTHER"
 )
 
+// IsKnown returns true if o is a payment object known to this version of the
+// client.
+func (o ItemPaymentObject) IsKnown() bool {
+	switch o {
+	case ItemPaymentObjectCommodity, ItemPaymentObjectExcise, ItemPaymentObjectJob, ItemPaymentObjectService,
+		ItemPaymentObjectGamblingBet, ItemPaymentObjectGamblingPrize, ItemPaymentObjectLottery,
+		ItemPaymentObjectLotteryPrize, ItemPaymentObjectIntellectualActivity, ItemPaymentObjectPayment,
+		ItemPaymentObjectAgentCommission, ItemPaymentObjectComposite, ItemPaymentObjectAnother:
+		return true
+	}
+	return false
+}
+
 // ItemTax represents the VAT rate for an item.
 type ItemTax string
 
@@ -145,30 +259,45 @@ const (
 	ItemTaxVAT120 ItemTax = "VAT120"
 )
 
+// IsKnown returns true if t is a VAT rate known to this version of the
+// client.
+func (t ItemTax) IsKnown() bool {
+	switch t {
+	case ItemTaxNone, ItemTaxVAT0, ItemTaxVAT10, ItemTaxVAT20, ItemTaxVAT110, ItemTaxVAT120:
+		return true
+	}
+	return false
+}
+
 // CreatePaymentRequest represents a request to create a payment.
 type CreatePaymentRequest struct {
 	RequestID        string            `json:"requestId"`
 	Amount           float64           `json:"amount,omitempty"`
-	Currency         string            `json:"currency,omitempty"`
+	Currency         Currency          `json:"currency,omitempty"`
 	Locale           string            `json:"locale,omitempty"`
 	FinishPaymentURL string            `json:"finishPaymentUrl,omitempty"`
 	NotificationURL  string            `json:"notificationUrl,omitempty"`
 	CustomerInfo     *CustomerInfo     `json:"customerInfo,omitempty"`
 	BrowserInfo      *BrowserInfo      `json:"browserInfo,omitempty"`
+	ItemsInfo        *ItemsInfo        `json:"itemsInfo,omitempty"`
 	AdditionalInfo   map[string]string `json:"additionalInfo,omitempty"`
 }
 
-// Payment represents payment details returned from the API.
+// Payment represents payment details returned from the API. It does not
+// report whether a successful payment has been confirmed; the
+// PaymentStatusResponse of GetPaymentStatus does, through its ConfirmedAmount.
 type Payment struct {
 	RequestID      string            `json:"requestId"`
 	PaymentID      string            `json:"paymentId"`
 	Status         PaymentStatus     `json:"status"`
 	Canceled       bool              `json:"canceled,omitempty"`
 	Amount         float64           `json:"amount"`
-	Currency       string            `json:"currency"`
+	Currency       Currency          `json:"currency"`
 	CreationDate   Time              `json:"creationDate"`
-	FormURL        string            `json:"formUrl,omitempty"`
+	FormURL        string            `json:"formUrl"`
 	AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
+
+	rawPayload
 }
 
 // PaymentStatusResponse represents the response when getting payment status.
@@ -179,22 +308,31 @@ type PaymentStatusResponse struct {
 	Canceled        bool              `json:"canceled,omitempty"`
 	Amount          float64           `json:"amount"`
 	ConfirmedAmount float64           `json:"confirmedAmount,omitempty"`
-	Currency        string            `json:"currency"`
-	PaymentType     string            `json:"paymentType,omitempty"`
+	Currency        Currency          `json:"currency"`
+	PaymentType     PaymentType       `json:"paymentType,omitempty"`
 	CreationDate    Time              `json:"creationDate"`
 	Details         *PaymentDetails   `json:"details,omitempty"`
 	AdditionalInfo  map[string]string `json:"additionalInfo,omitempty"`
+
+	// Cancels is an extension: paymentStatusResponse in the API
+	// specification has no cancels, so it is only set if the gateway returns
+	// them in the shape of PaymentCancelResponse.Cancels.
+	Cancels []Cancel `json:"cancels,omitempty"`
+
+	rawPayload
 }
 
 // PaymentDetails contains detailed information about a payment.
 type PaymentDetails struct {
-	ResultCode    string                 `json:"resultCode,omitempty"`
-	RRN           string                 `json:"rrn,omitempty"`
-	AuthID        string                 `json:"authId,omitempty"`
-	AuthDate      *Time                  `json:"authDate,omitempty"`
-	MaskedPan     string                 `json:"maskedPan,omitempty"`
-	PaymentSystem PaymentSystem          `json:"paymentSystem,omitempty"`
-	CustomDetails map[string]interface{} `json:"customDetails,omitempty"`
+	ResultCode        string                 `json:"resultCode,omitempty"`
+	ResultDescription string                 `json:"resultDescription,omitempty"`
+	RRN               string                 `json:"rrn,omitempty"`
+	ExternalRRN       string                 `json:"externalRrn,omitempty"`
+	AuthID            string                 `json:"authId,omitempty"`
+	AuthDate          *Time                  `json:"authDate,omitempty"`
+	MaskedPan         string                 `json:"maskedPan,omitempty"`
+	PaymentSystem     PaymentSystem          `json:"paymentSystem,omitempty"`
+	CustomDetails     map[string]interface{} `json:"customDetails,omitempty"`
 }
 
 // CancelPaymentRequest represents a request to cancel a payment.
@@ -210,10 +348,19 @@ type PaymentCancelResponse struct {
 	Status         PaymentStatus     `json:"status"`
 	Canceled       bool              `json:"canceled"`
 	Amount         float64           `json:"amount"`
-	Currency       string            `json:"currency"`
+	Currency       Currency          `json:"currency"`
 	CreationDate   Time              `json:"creationDate"`
 	Cancels        []Cancel          `json:"cancels,omitempty"`
 	AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
+
+	rawPayload
+}
+
+// ConfirmPaymentRequest represents a request to confirm (capture) a payment
+// that was authorized and is waiting for confirmation.
+type ConfirmPaymentRequest struct {
+	RequestID string  `json:"requestId,omitempty"`
+	Amount    float64 `json:"amount,omitempty"`
 }
 
 // Cancel represents cancellation details.
@@ -245,41 +392,44 @@ type Refund struct {
 	RequestID    string          `json:"requestId,omitempty"`
 	PaymentID    string          `json:"paymentId"`
 	Amount       float64         `json:"amount"`
-	Currency     string          `json:"currency"`
+	Currency     Currency        `json:"currency"`
 	CreationDate Time            `json:"creationDate"`
 	Message      string          `json:"message,omitempty"`
 	Details      *PaymentDetails `json:"details,omitempty"`
 	Status       RefundStatus    `json:"status"`
+	Successful   bool            `json:"successful"`
 	Canceled     bool            `json:"canceled,omitempty"`
 	Cancels      []Cancel        `json:"cancels,omitempty"`
+
+	rawPayload
 }
 
 // CustomerInfo contains customer details.
 type CustomerInfo struct {
-	FirstName                    string `json:"firstName,omitempty"`
-	MiddleName                   string `json:"middleName,omitempty"`
-	LastName                     string `json:"lastName,omitempty"`
-	Phone                        string `json:"phone,omitempty"`
-	Email                        string `json:"email,omitempty"`
-	AccountID                    string `json:"accountId,omitempty"`
-	AccountNumber                string `json:"accountNumber,omitempty"`
-	Address                      string `json:"address,omitempty"`
-	City                         string `json:"city,omitempty"`
-	ProvinceCode                 string `json:"provinceCode,omitempty"`
-	CountryCode                  string `json:"countryCode,omitempty"`
-	PostalCode                   string `json:"postalCode,omitempty"`
-	BirthDate                    string `json:"birthDate,omitempty"`
-	IdentificationType           string `json:"identificationType,omitempty"`
-	IdentificationNumber         string `json:"identificationNumber,omitempty"`
-	IdentificationCountryCode    string `json:"identificationCountryCode,omitempty"`
-	IdentificationExpirationDate string `json:"identificationExpirationDate,omitempty"`
-	Nationality                  string `json:"nationality,omitempty"`
-	CountryOfBirth               string `json:"countryOfBirth,omitempty"`
-	FundSource                   string `json:"fundSource,omitempty"`
-	ParticipantID                string `json:"participantId,omitempty"`
-	AdditionalMessage            string `json:"additionalMessage,omitempty"`
-	TransactionReason            string `json:"transactionReason,omitempty"`
-	ClaimCode                    string `json:"claimCode,omitempty"`
+	FirstName                    string             `json:"firstName,omitempty"`
+	MiddleName                   string             `json:"middleName,omitempty"`
+	LastName                     string             `json:"lastName,omitempty"`
+	Phone                        string             `json:"phone,omitempty"`
+	Email                        string             `json:"email,omitempty"`
+	AccountID                    string             `json:"accountId,omitempty"`
+	AccountNumber                string             `json:"accountNumber,omitempty"`
+	Address                      string             `json:"address,omitempty"`
+	City                         string             `json:"city,omitempty"`
+	ProvinceCode                 Province           `json:"provinceCode,omitempty"`
+	CountryCode                  Country            `json:"countryCode,omitempty"`
+	PostalCode                   string             `json:"postalCode,omitempty"`
+	BirthDate                    string             `json:"birthDate,omitempty"`
+	IdentificationType           IdentificationType `json:"identificationType,omitempty"`
+	IdentificationNumber         string             `json:"identificationNumber,omitempty"`
+	IdentificationCountryCode    Country            `json:"identificationCountryCode,omitempty"`
+	IdentificationExpirationDate string             `json:"identificationExpirationDate,omitempty"`
+	Nationality                  Country            `json:"nationality,omitempty"`
+	CountryOfBirth               Country            `json:"countryOfBirth,omitempty"`
+	FundSource                   FundSource         `json:"fundSource,omitempty"`
+	ParticipantID                string             `json:"participantId,omitempty"`
+	AdditionalMessage            string             `json:"additionalMessage,omitempty"`
+	TransactionReason            string             `json:"transactionReason,omitempty"`
+	ClaimCode                    string             `json:"claimCode,omitempty"`
 }
 
 // BrowserInfo contains browser details for 3DS authentication.
@@ -0,0 +1,664 @@
+package qi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+// specModels maps the schemas of docs/openapi.yaml to the models
+// implementing them.
+var specModels = map[string]reflect.Type{
+	"CancelPaymentRequest":  reflect.TypeOf(qi.CancelPaymentRequest{}),
+	"CreateRefundRequest":   reflect.TypeOf(qi.CreateRefundRequest{}),
+	"Refund":                reflect.TypeOf(qi.Refund{}),
+	"AuthenticateInfo":      reflect.TypeOf(qi.AuthenticateInfo{}),
+	"CreatePaymentRequest":  reflect.TypeOf(qi.CreatePaymentRequest{}),
+	"Payment":               reflect.TypeOf(qi.Payment{}),
+	"paymentStatusResponse": reflect.TypeOf(qi.PaymentStatusResponse{}),
+	"paymentCancelResponse": reflect.TypeOf(qi.PaymentCancelResponse{}),
+	"PaymentDetails":        reflect.TypeOf(qi.PaymentDetails{}),
+	"PaymentData":           reflect.TypeOf(qi.PaymentData{}),
+	"CustomerInfo":          reflect.TypeOf(qi.CustomerInfo{}),
+	"BrowserInfo":           reflect.TypeOf(qi.BrowserInfo{}),
+	"ItemsInfo":             reflect.TypeOf(qi.ItemsInfo{}),
+	"PaymentItem":           reflect.TypeOf(qi.PaymentItem{}),
+	"Error":                 reflect.TypeOf(qi.Error{}),
+	"Cancel":                reflect.TypeOf(qi.Cancel{}),
+}
+
+// specEnums maps the enum schemas of docs/openapi.yaml to the types whose
+// constants implement them.
+var specEnums = map[string]string{
+	"PaymentStatus":     "PaymentStatus",
+	"PaymentType":       "PaymentType",
+	"PaymentTokenType":  "PaymentTokenType",
+	"ItemPaymentMethod": "ItemPaymentMethod",
+	"ItemPaymentObject": "ItemPaymentObject",
+	"ItemTax":           "ItemTax",
+}
+
+// enumIsKnown calls the IsKnown method of the enum types in specEnums.
+var enumIsKnown = map[string]func(v string) bool{
+	"PaymentStatus":     func(v string) bool { return qi.PaymentStatus(v).IsKnown() },
+	"PaymentType":       func(v string) bool { return qi.PaymentType(v).IsKnown() },
+	"PaymentTokenType":  func(v string) bool { return qi.PaymentTokenType(v).IsKnown() },
+	"ItemPaymentMethod": func(v string) bool { return qi.ItemPaymentMethod(v).IsKnown() },
+	"ItemPaymentObject": func(v string) bool { return qi.ItemPaymentObject(v).IsKnown() },
+	"ItemTax":           func(v string) bool { return qi.ItemTax(v).IsKnown() },
+}
+
+// enumExtensions lists enum constants that are deliberately not in the spec
+// enum, keyed by type and value.
+var enumExtensions = map[string]string{
+	"PaymentType.CARD": "example of paymentStatusResponse.paymentType, not in the PaymentType enum",
+}
+
+// specExtensions lists model fields that are deliberately not in the spec.
+var specExtensions = map[string]string{
+	"CreatePaymentRequest.itemsInfo": "the ItemsInfo schema is defined but not referenced",
+	"paymentStatusResponse.cancels":  "extension with the shape of PaymentCancelResponse.cancels, not in the spec",
+}
+
+func loadSpecSchemas(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile("docs/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := parseYAML(data)
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	schemas := lookup(spec, "components", "schemas")
+	if schemas == nil {
+		t.Fatal("spec has no components.schemas")
+	}
+	return schemas
+}
+
+func TestSpecModels(t *testing.T) {
+	schemas := loadSpecSchemas(t)
+	consts := packageConstants(t)
+
+	for name, typ := range specModels {
+		schema, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("schema %s not found in spec", name)
+			continue
+		}
+		checkObject(t, schemas, consts, name, schema, typ)
+	}
+}
+
+func TestSpecEnums(t *testing.T) {
+	schemas := loadSpecSchemas(t)
+	consts := packageConstants(t)
+
+	for name, typ := range specEnums {
+		schema, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("schema %s not found in spec", name)
+			continue
+		}
+		checkEnum(t, name, schema, consts[typ])
+
+		isKnown := enumIsKnown[typ]
+		if isKnown == nil {
+			t.Errorf("%s: no IsKnown method", typ)
+			continue
+		}
+		for _, v := range asList(schema["enum"]) {
+			if !isKnown(v.(string)) {
+				t.Errorf("%s: spec value %s is not known", typ, v)
+			}
+		}
+		if isKnown("UNDOCUMENTED") {
+			t.Errorf("%s: undocumented value is known", typ)
+		}
+	}
+}
+
+func TestErrorCodesKnown(t *testing.T) {
+	codes := packageConstants(t)["ErrorCode"]
+	known := map[int]bool{}
+	for _, v := range codes {
+		code, _ := strconv.Atoi(v)
+		known[code] = true
+	}
+	for code := -1; code <= len(codes)+2; code++ {
+		if got := qi.ErrorCode(code).IsKnown(); got != known[code] {
+			t.Errorf("ErrorCode(%d).IsKnown() = %v, want %v", code, got, known[code])
+		}
+	}
+}
+
+func TestSpecExamplesRoundTrip(t *testing.T) {
+	schemas := loadSpecSchemas(t)
+
+	for name, typ := range specModels {
+		schema, _ := schemas[name].(map[string]interface{})
+		example := exampleFor(schemas, schema)
+		data, err := json.Marshal(example)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := reflect.New(typ).Interface()
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			t.Errorf("%s: failed to decode example %s: %v", name, data, err)
+			continue
+		}
+
+		out, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("%s: failed to encode: %v", name, err)
+			continue
+		}
+		var got interface{}
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatal(err)
+		}
+		var want interface{}
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatal(err)
+		}
+		compareJSON(t, name, want, got)
+	}
+}
+
+func checkObject(t *testing.T, schemas map[string]interface{}, consts map[string][]string, path string, schema map[string]interface{}, typ reflect.Type) {
+	t.Helper()
+	props, _ := schema["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	for _, r := range asList(schema["required"]) {
+		required[r.(string)] = true
+	}
+
+	fields := jsonFields(typ)
+	for name := range required {
+		if _, ok := props[name]; !ok {
+			if field, ok := fields[name]; !ok || field.omitempty {
+				t.Errorf("%s.%s: required property is not defined and not a required field of %s", path, name, typ.Name())
+			}
+		}
+	}
+	for name, prop := range props {
+		field, ok := fields[name]
+		if !ok {
+			t.Errorf("%s.%s: property missing from %s", path, name, typ.Name())
+			continue
+		}
+		if required[name] && field.omitempty {
+			t.Errorf("%s.%s: required property is omitempty in %s", path, name, typ.Name())
+		}
+		checkProperty(t, schemas, consts, path+"."+name, resolve(schemas, prop), field.typ)
+	}
+	// Inline schemas may describe a subset of a model checked on its own.
+	if strings.Contains(path, ".") && isSpecModel(typ) {
+		return
+	}
+	for name := range fields {
+		if _, ok := props[name]; !ok && !required[name] {
+			if _, ok := specExtensions[path+"."+name]; !ok {
+				t.Errorf("%s.%s: field of %s not in spec", path, name, typ.Name())
+			}
+		}
+	}
+}
+
+func isSpecModel(typ reflect.Type) bool {
+	for _, model := range specModels {
+		if model == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func checkProperty(t *testing.T, schemas map[string]interface{}, consts map[string][]string, path string, prop map[string]interface{}, typ reflect.Type) {
+	t.Helper()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	specType, _ := prop["type"].(string)
+	if specType == "" && prop["properties"] != nil {
+		specType = "object"
+	}
+	if typ == reflect.TypeOf(qi.Time{}) {
+		if specType != "string" {
+			t.Errorf("%s: %s is a time but spec type is %q", path, typ.Name(), specType)
+		}
+		return
+	}
+
+	var kinds []reflect.Kind
+	switch specType {
+	case "string":
+		kinds = []reflect.Kind{reflect.String}
+	case "number":
+		kinds = []reflect.Kind{reflect.Float64, reflect.Float32}
+	case "integer":
+		kinds = []reflect.Kind{reflect.Int, reflect.Int32, reflect.Int64}
+	case "boolean":
+		kinds = []reflect.Kind{reflect.Bool}
+	case "array":
+		kinds = []reflect.Kind{reflect.Slice}
+	case "object":
+		kinds = []reflect.Kind{reflect.Struct, reflect.Map}
+	}
+	ok := false
+	for _, k := range kinds {
+		ok = ok || typ.Kind() == k
+	}
+	if !ok {
+		t.Errorf("%s: spec type %q does not match Go type %s", path, specType, typ)
+		return
+	}
+
+	switch {
+	case specType == "array" && prop["items"] != nil:
+		checkProperty(t, schemas, consts, path+"[]", resolve(schemas, prop["items"]), typ.Elem())
+	case specType == "object" && typ.Kind() == reflect.Struct:
+		checkObject(t, schemas, consts, path, prop, typ)
+	case prop["enum"] != nil:
+		if values, ok := consts[typ.Name()]; ok {
+			checkEnum(t, path, prop, values)
+		}
+	}
+}
+
+func checkEnum(t *testing.T, path string, schema map[string]interface{}, values []string) {
+	t.Helper()
+	var want []string
+	for _, v := range asList(schema["enum"]) {
+		want = append(want, v.(string))
+	}
+	// Constants may give several names to the same value.
+	var got []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			got = append(got, v)
+		}
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s: spec enum %v does not match constants %v", path, want, got)
+	}
+}
+
+type jsonField struct {
+	typ       reflect.Type
+	omitempty bool
+}
+
+func jsonFields(typ reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{typ: f.Type, omitempty: strings.Contains(opts, "omitempty")}
+	}
+	return fields
+}
+
+// packageConstants returns the values of the typed constants declared in the
+// package, keyed by type name.
+func packageConstants(t *testing.T) map[string][]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consts := map[string][]string{}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					ident, ok := vs.Type.(*ast.Ident)
+					if !ok || len(vs.Values) != len(vs.Names) {
+						continue
+					}
+					for _, v := range vs.Values {
+						lit, ok := v.(*ast.BasicLit)
+						if !ok {
+							continue
+						}
+						value := lit.Value
+						if lit.Kind == token.STRING {
+							value, _ = strconv.Unquote(value)
+						}
+						if _, ok := enumExtensions[ident.Name+"."+value]; ok {
+							continue
+						}
+						consts[ident.Name] = append(consts[ident.Name], value)
+					}
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// exampleFor builds an example value from the examples in a schema.
+func exampleFor(schemas map[string]interface{}, node interface{}) interface{} {
+	schema := resolve(schemas, node)
+	if example, ok := schema["example"]; ok {
+		return convertExample(schemas, schema, example)
+	}
+	switch {
+	case schema["properties"] != nil:
+		obj := map[string]interface{}{}
+		for name, prop := range schema["properties"].(map[string]interface{}) {
+			if v := exampleFor(schemas, prop); v != nil {
+				obj[name] = v
+			}
+		}
+		return obj
+	case schema["items"] != nil:
+		if v := exampleFor(schemas, schema["items"]); v != nil {
+			return []interface{}{v}
+		}
+	}
+	return nil
+}
+
+// convertExample converts a YAML example to the JSON type of its schema.
+func convertExample(schemas map[string]interface{}, schema map[string]interface{}, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		obj := map[string]interface{}{}
+		for name, val := range v {
+			obj[name] = convertExample(schemas, resolve(schemas, props[name]), val)
+		}
+		return obj
+	case []interface{}:
+		items := resolve(schemas, schema["items"])
+		list := make([]interface{}, len(v))
+		for i, val := range v {
+			list[i] = convertExample(schemas, items, val)
+		}
+		return list
+	case string:
+		switch schema["type"] {
+		case "number":
+			f, _ := strconv.ParseFloat(v, 64)
+			return f
+		case "integer":
+			n, _ := strconv.Atoi(v)
+			return n
+		case "boolean":
+			return v == "true"
+		}
+	}
+	return v
+}
+
+func compareJSON(t *testing.T, path string, want, got interface{}) {
+	t.Helper()
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, _ := got.(map[string]interface{})
+		for name, wv := range w {
+			gv, ok := g[name]
+			if !ok {
+				if !isZeroJSON(wv) {
+					t.Errorf("%s.%s: lost in round trip", path, name)
+				}
+				continue
+			}
+			compareJSON(t, path+"."+name, wv, gv)
+		}
+	case []interface{}:
+		g, _ := got.([]interface{})
+		if len(g) != len(w) {
+			t.Errorf("%s: expected %d items, got %d", path, len(w), len(g))
+			return
+		}
+		for i := range w {
+			compareJSON(t, fmt.Sprintf("%s[%d]", path, i), w[i], g[i])
+		}
+	case string:
+		if g, ok := got.(string); ok && (g == w || sameTime(w, g)) {
+			return
+		}
+		t.Errorf("%s: expected %q, got %v", path, w, got)
+	default:
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func isZeroJSON(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	}
+	return false
+}
+
+func sameTime(a, b string) bool {
+	parse := func(s string) (time.Time, bool) {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	ta, okA := parse(a)
+	tb, okB := parse(b)
+	return okA && okB && ta.Equal(tb)
+}
+
+// resolve follows a $ref to a component schema.
+func resolve(schemas map[string]interface{}, node interface{}) map[string]interface{} {
+	schema, _ := node.(map[string]interface{})
+	if ref, ok := schema["$ref"].(string); ok {
+		target, _ := schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+		return target
+	}
+	return schema
+}
+
+func lookup(node interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		m, _ := node.(map[string]interface{})
+		node = m[key]
+	}
+	m, _ := node.(map[string]interface{})
+	return m
+}
+
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// parseYAML parses the block-style subset of YAML used by the spec into maps,
+// lists and strings. Descriptions are parsed as opaque scalars.
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for _, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(text) - len(trimmed), text: stripComment(trimmed)})
+	}
+	p := &yamlParser{lines: lines}
+	v := p.block(0, "")
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("unexpected line %q", p.lines[p.pos].text)
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+var yamlKey = regexp.MustCompile(`^([A-Za-z0-9_$./{}-]+|"[^"]*"|'[^']*'):(?:\s+(.*))?$`)
+
+func (p *yamlParser) block(indent int, parent string) interface{} {
+	if p.pos >= len(p.lines) {
+		return nil
+	}
+	if isListItem(p.lines[p.pos].text) {
+		return p.list(indent)
+	}
+	return p.mapping(indent, parent)
+}
+
+func (p *yamlParser) mapping(indent int, parent string) map[string]interface{} {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || isListItem(line.text) {
+			break
+		}
+		match := yamlKey.FindStringSubmatch(line.text)
+		if match == nil {
+			break
+		}
+		key, value := unquote(match[1]), match[2]
+		p.pos++
+
+		isDescription := key == "description" && parent != "properties"
+		if value != "" || isDescription || !p.childIsBlock(indent) {
+			m[key] = p.scalar(value, indent)
+			continue
+		}
+		m[key] = p.block(p.lines[p.pos].indent, key)
+	}
+	return m
+}
+
+func (p *yamlParser) list(indent int) []interface{} {
+	var list []interface{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isListItem(line.text) {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if yamlKey.MatchString(rest) {
+			p.lines[p.pos] = yamlLine{indent: indent + 2, text: rest}
+			list = append(list, p.mapping(indent+2, ""))
+			continue
+		}
+		p.pos++
+		list = append(list, p.scalar(rest, indent))
+	}
+	return list
+}
+
+// childIsBlock returns true if the lines following a key with an empty value
+// form a nested mapping or list rather than a multi-line scalar.
+func (p *yamlParser) childIsBlock(indent int) bool {
+	if p.pos >= len(p.lines) {
+		return false
+	}
+	next := p.lines[p.pos]
+	if next.indent < indent || (next.indent == indent && !isListItem(next.text)) {
+		return false
+	}
+	return isListItem(next.text) || yamlKey.MatchString(next.text)
+}
+
+// scalar joins value with its continuation lines.
+func (p *yamlParser) scalar(value string, indent int) interface{} {
+	parts := []string{}
+	if value != "" {
+		parts = append(parts, value)
+	}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		parts = append(parts, p.lines[p.pos].text)
+		p.pos++
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return unquote(strings.Join(parts, " "))
+}
+
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// stripComment removes a trailing comment outside of quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' {
+				quote = c
+			}
+		case c == '#' && i > 0 && s[i-1] == ' ':
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
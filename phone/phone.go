@@ -0,0 +1,135 @@
+// Package phone parses and formats Iraqi mobile phone numbers.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CountryCode is the Iraqi country calling code.
+const CountryCode = "964"
+
+var (
+	// ErrInvalidNumber is returned for input that is not an Iraqi mobile
+	// number.
+	ErrInvalidNumber = errors.New("phone: invalid Iraqi mobile number")
+	// ErrUnknownOperator is returned for mobile numbers with a prefix that is
+	// not assigned to a known operator.
+	ErrUnknownOperator = errors.New("phone: unknown mobile operator prefix")
+)
+
+// Operator is an Iraqi mobile network operator.
+type Operator string
+
+const (
+	OperatorAsiacell Operator = "Asiacell"
+	OperatorKorek    Operator = "Korek"
+	OperatorZain     Operator = "Zain"
+)
+
+// operatorPrefixes maps the first two digits of the national number to the
+// operator they are assigned to.
+var operatorPrefixes = map[string]Operator{
+	"75": OperatorKorek,
+	"77": OperatorAsiacell,
+	"78": OperatorZain,
+	"79": OperatorZain,
+}
+
+// nationalLength is the number of digits of an Iraqi mobile number after
+// the country code, e.g. 7701234567.
+const nationalLength = 10
+
+// Number is an Iraqi mobile number in E.164 format, e.g. +9647701234567.
+type Number string
+
+// Parse parses a mobile number in local (07701234567), international
+// (009647701234567, +9647701234567, 9647701234567) or national (7701234567)
+// form. Spaces, dashes, dots and parentheses are ignored.
+func Parse(s string) (Number, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, s)
+
+	national := digits
+	switch {
+	case strings.HasPrefix(digits, "+"+CountryCode):
+		national = digits[len(CountryCode)+1:]
+	case strings.HasPrefix(digits, "00"+CountryCode):
+		national = digits[len(CountryCode)+2:]
+	case strings.HasPrefix(digits, CountryCode) && len(digits) == len(CountryCode)+nationalLength:
+		national = digits[len(CountryCode):]
+	case strings.HasPrefix(digits, "0") && len(digits) == nationalLength+1:
+		national = digits[1:]
+	}
+
+	if len(national) != nationalLength || national[0] != '7' || !isDigits(national) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+	if _, ok := operatorPrefixes[national[:2]]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownOperator, s)
+	}
+	return Number("+" + CountryCode + national), nil
+}
+
+// Valid returns true if s can be parsed as an Iraqi mobile number.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the number in E.164 format.
+func (n Number) String() string {
+	return string(n)
+}
+
+// E164 returns the number in E.164 format, e.g. +9647701234567.
+func (n Number) E164() string {
+	return string(n)
+}
+
+// National returns the number without the country code, e.g. 7701234567.
+func (n Number) National() string {
+	return strings.TrimPrefix(string(n), "+"+CountryCode)
+}
+
+// Local returns the number as dialled within Iraq, e.g. 07701234567.
+func (n Number) Local() string {
+	return "0" + n.National()
+}
+
+// International returns the number with the 00 international prefix, e.g.
+// 009647701234567. This is the form used for customerInfo.phone.
+func (n Number) International() string {
+	return "00" + CountryCode + n.National()
+}
+
+// Digits returns the number with the country code and no prefix, e.g.
+// 9647701234567. This is the form used for the phone of Instant payment
+// refunds.
+func (n Number) Digits() string {
+	return CountryCode + n.National()
+}
+
+// Operator returns the mobile operator the number's prefix is assigned to.
+func (n Number) Operator() Operator {
+	national := n.National()
+	if len(national) < 2 {
+		return ""
+	}
+	return operatorPrefixes[national[:2]]
+}
@@ -0,0 +1,71 @@
+package phone_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BynxDev/qi/phone"
+)
+
+func TestParse(t *testing.T) {
+	tests := []string{
+		"07701234567",
+		"0770 123 4567",
+		"+9647701234567",
+		"+964 770 123-4567",
+		"009647701234567",
+		"9647701234567",
+		"7701234567",
+	}
+
+	for _, input := range tests {
+		n, err := phone.Parse(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+			continue
+		}
+		if n.E164() != "+9647701234567" {
+			t.Errorf("%q: expected +9647701234567, got %s", input, n)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"", phone.ErrInvalidNumber},
+		{"0770123456", phone.ErrInvalidNumber},
+		{"+96417701234", phone.ErrInvalidNumber},
+		{"+447700900123", phone.ErrInvalidNumber},
+		{"0770123456a", phone.ErrInvalidNumber},
+		{"07101234567", phone.ErrUnknownOperator},
+	}
+
+	for _, tt := range tests {
+		if _, err := phone.Parse(tt.input); !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.err, err)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	n, err := phone.Parse("07801234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Local() != "07801234567" {
+		t.Errorf("unexpected local form %s", n.Local())
+	}
+	if n.International() != "009647801234567" {
+		t.Errorf("unexpected international form %s", n.International())
+	}
+	if n.Digits() != "9647801234567" {
+		t.Errorf("unexpected digits form %s", n.Digits())
+	}
+	if n.Operator() != phone.OperatorZain {
+		t.Errorf("expected Zain, got %s", n.Operator())
+	}
+}
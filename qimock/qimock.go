@@ -0,0 +1,195 @@
+// Package qimock provides a mock implementation of qi.PaymentGateway for
+// tests.
+package qimock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/BynxDev/qi"
+)
+
+// Call records a call made to a Gateway.
+type Call struct {
+	Operation string
+	Args      []interface{}
+	Opts      []qi.CallOption
+}
+
+// Gateway is a qi.PaymentGateway whose methods call the corresponding Func
+// field. Calls to methods whose Func is nil return ErrNotImplemented.
+type Gateway struct {
+	CreatePaymentFunc              func(ctx context.Context, req *qi.CreatePaymentRequest) (*qi.Payment, error)
+	GetPaymentStatusFunc           func(ctx context.Context, paymentID string) (*qi.PaymentStatusResponse, error)
+	GetPaymentStatusByRequestFunc  func(ctx context.Context, requestID string) (*qi.PaymentStatusResponse, error)
+	CancelPaymentFunc              func(ctx context.Context, paymentID string, req *qi.CancelPaymentRequest) (*qi.PaymentCancelResponse, error)
+	CancelPaymentByRequestFunc     func(ctx context.Context, requestID string, req *qi.CancelPaymentRequest) (*qi.PaymentCancelResponse, error)
+	ConfirmPaymentFunc             func(ctx context.Context, paymentID string, req *qi.ConfirmPaymentRequest) (*qi.PaymentStatusResponse, error)
+	ConfirmPaymentByRequestFunc    func(ctx context.Context, requestID string, req *qi.ConfirmPaymentRequest) (*qi.PaymentStatusResponse, error)
+	RefundPaymentFunc              func(ctx context.Context, paymentID string, req *qi.CreateRefundRequest) (*qi.Refund, error)
+	RefundPaymentByRequestFunc     func(ctx context.Context, requestID string, req *qi.CreateRefundRequest) (*qi.Refund, error)
+	CreateTransferFunc             func(ctx context.Context, req *qi.CreateTransferRequest) (*qi.Transfer, error)
+	GetTransferStatusFunc          func(ctx context.Context, transferID string) (*qi.Transfer, error)
+	GetTransferStatusByRequestFunc func(ctx context.Context, requestID string) (*qi.Transfer, error)
+	CancelTransferFunc             func(ctx context.Context, transferID string, req *qi.CancelTransferRequest) (*qi.Transfer, error)
+	CancelTransferByRequestFunc    func(ctx context.Context, requestID string, req *qi.CancelTransferRequest) (*qi.Transfer, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ qi.PaymentGateway = (*Gateway)(nil)
+
+// ErrNotImplemented is returned by Gateway methods without a Func.
+var ErrNotImplemented = errors.New("qimock: method not implemented")
+
+func (g *Gateway) record(operation string, opts []qi.CallOption, args ...interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = append(g.calls, Call{Operation: operation, Args: args, Opts: opts})
+}
+
+// Calls returns the calls made so far.
+func (g *Gateway) Calls() []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Call(nil), g.calls...)
+}
+
+// CallsTo returns the calls made so far to operation.
+func (g *Gateway) CallsTo(operation string) []Call {
+	var calls []Call
+	for _, c := range g.Calls() {
+		if c.Operation == operation {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// CreatePayment implements qi.PaymentGateway.
+func (g *Gateway) CreatePayment(ctx context.Context, req *qi.CreatePaymentRequest, opts ...qi.CallOption) (*qi.Payment, error) {
+	g.record(qi.OperationCreatePayment, opts, req)
+	if g.CreatePaymentFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CreatePaymentFunc(ctx, req)
+}
+
+// GetPaymentStatus implements qi.PaymentGateway.
+func (g *Gateway) GetPaymentStatus(ctx context.Context, paymentID string, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error) {
+	g.record(qi.OperationGetPaymentStatus, opts, paymentID)
+	if g.GetPaymentStatusFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.GetPaymentStatusFunc(ctx, paymentID)
+}
+
+// GetPaymentStatusByRequest implements qi.PaymentGateway.
+func (g *Gateway) GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error) {
+	g.record(qi.OperationGetPaymentStatusByRequest, opts, requestID)
+	if g.GetPaymentStatusByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.GetPaymentStatusByRequestFunc(ctx, requestID)
+}
+
+// CancelPayment implements qi.PaymentGateway.
+func (g *Gateway) CancelPayment(ctx context.Context, paymentID string, req *qi.CancelPaymentRequest, opts ...qi.CallOption) (*qi.PaymentCancelResponse, error) {
+	g.record(qi.OperationCancelPayment, opts, paymentID, req)
+	if g.CancelPaymentFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CancelPaymentFunc(ctx, paymentID, req)
+}
+
+// CancelPaymentByRequest implements qi.PaymentGateway.
+func (g *Gateway) CancelPaymentByRequest(ctx context.Context, requestID string, req *qi.CancelPaymentRequest, opts ...qi.CallOption) (*qi.PaymentCancelResponse, error) {
+	g.record(qi.OperationCancelPaymentByRequest, opts, requestID, req)
+	if g.CancelPaymentByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CancelPaymentByRequestFunc(ctx, requestID, req)
+}
+
+// ConfirmPayment implements qi.PaymentGateway.
+func (g *Gateway) ConfirmPayment(ctx context.Context, paymentID string, req *qi.ConfirmPaymentRequest, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error) {
+	g.record(qi.OperationConfirmPayment, opts, paymentID, req)
+	if g.ConfirmPaymentFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.ConfirmPaymentFunc(ctx, paymentID, req)
+}
+
+// ConfirmPaymentByRequest implements qi.PaymentGateway.
+func (g *Gateway) ConfirmPaymentByRequest(ctx context.Context, requestID string, req *qi.ConfirmPaymentRequest, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error) {
+	g.record(qi.OperationConfirmPaymentByRequest, opts, requestID, req)
+	if g.ConfirmPaymentByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.ConfirmPaymentByRequestFunc(ctx, requestID, req)
+}
+
+// RefundPayment implements qi.PaymentGateway.
+func (g *Gateway) RefundPayment(ctx context.Context, paymentID string, req *qi.CreateRefundRequest, opts ...qi.CallOption) (*qi.Refund, error) {
+	g.record(qi.OperationRefundPayment, opts, paymentID, req)
+	if g.RefundPaymentFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.RefundPaymentFunc(ctx, paymentID, req)
+}
+
+// RefundPaymentByRequest implements qi.PaymentGateway.
+func (g *Gateway) RefundPaymentByRequest(ctx context.Context, requestID string, req *qi.CreateRefundRequest, opts ...qi.CallOption) (*qi.Refund, error) {
+	g.record(qi.OperationRefundPaymentByRequest, opts, requestID, req)
+	if g.RefundPaymentByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.RefundPaymentByRequestFunc(ctx, requestID, req)
+}
+
+// CreateTransfer implements qi.PaymentGateway.
+func (g *Gateway) CreateTransfer(ctx context.Context, req *qi.CreateTransferRequest, opts ...qi.CallOption) (*qi.Transfer, error) {
+	g.record(qi.OperationCreateTransfer, opts, req)
+	if g.CreateTransferFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CreateTransferFunc(ctx, req)
+}
+
+// GetTransferStatus implements qi.PaymentGateway.
+func (g *Gateway) GetTransferStatus(ctx context.Context, transferID string, opts ...qi.CallOption) (*qi.Transfer, error) {
+	g.record(qi.OperationGetTransferStatus, opts, transferID)
+	if g.GetTransferStatusFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.GetTransferStatusFunc(ctx, transferID)
+}
+
+// GetTransferStatusByRequest implements qi.PaymentGateway.
+func (g *Gateway) GetTransferStatusByRequest(ctx context.Context, requestID string, opts ...qi.CallOption) (*qi.Transfer, error) {
+	g.record(qi.OperationGetTransferStatusByRequest, opts, requestID)
+	if g.GetTransferStatusByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.GetTransferStatusByRequestFunc(ctx, requestID)
+}
+
+// CancelTransfer implements qi.PaymentGateway.
+func (g *Gateway) CancelTransfer(ctx context.Context, transferID string, req *qi.CancelTransferRequest, opts ...qi.CallOption) (*qi.Transfer, error) {
+	g.record(qi.OperationCancelTransfer, opts, transferID, req)
+	if g.CancelTransferFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CancelTransferFunc(ctx, transferID, req)
+}
+
+// CancelTransferByRequest implements qi.PaymentGateway.
+func (g *Gateway) CancelTransferByRequest(ctx context.Context, requestID string, req *qi.CancelTransferRequest, opts ...qi.CallOption) (*qi.Transfer, error) {
+	g.record(qi.OperationCancelTransferByRequest, opts, requestID, req)
+	if g.CancelTransferByRequestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return g.CancelTransferByRequestFunc(ctx, requestID, req)
+}
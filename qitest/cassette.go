@@ -0,0 +1,345 @@
+// Package qitest records exchanges with the QiCard Payment Gateway and replays
+// them in tests without network access.
+package qitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Redacted replaces credentials and personal data in recorded interactions.
+const Redacted = "REDACTED"
+
+// Request headers that are dropped or redacted when recording.
+var (
+	strippedHeaders  = []string{"X-Signature", "Cookie", "Set-Cookie"}
+	redactedHeaders  = []string{"Authorization", "X-Terminal-Id"}
+	recordedHeaders  = []string{"Content-Type", "Accept", "Authorization", "X-Terminal-Id"}
+	responseExcluded = []string{"Date", "Set-Cookie", "X-Signature"}
+)
+
+// PIIFields are the JSON body fields whose values are redacted when
+// recording, such as customer names, phone numbers and card details.
+var PIIFields = []string{
+	"firstName", "middleName", "lastName", "phone", "email",
+	"accountId", "accountNumber", "address", "city", "postalCode", "birthDate",
+	"identificationNumber", "identificationExpirationDate", "claimCode",
+	"browserIp", "maskedPan", "rrn", "authId",
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request. A JSON body is recorded in Body, any
+// other body verbatim in BodyText.
+type Request struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Header   http.Header     `json:"header,omitempty"`
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"bodyText,omitempty"`
+}
+
+// Response is a recorded HTTP response. A JSON body is recorded in Body, any
+// other body, such as an HTML error page, verbatim in BodyText.
+type Response struct {
+	StatusCode int             `json:"statusCode"`
+	Header     http.Header     `json:"header,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	BodyText   string          `json:"bodyText,omitempty"`
+}
+
+// Cassette is a fixture file of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cassette: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Recorder is an http.RoundTripper that passes requests to the next
+// transport and records the redacted exchanges.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a new Recorder. A nil next uses
+// http.DefaultTransport.
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Header: recordRequestHeader(req.Header),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     recordResponseHeader(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyText = redactBody(reqBody)
+	interaction.Response.Body, interaction.Response.BodyText = redactBody(respBody)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the interactions recorded so far to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette.
+// Requests are matched by method, path and normalized body, and each
+// interaction is replayed once.
+type Replayer struct {
+	t testing.TB
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer creates a new Replayer from the cassette at path. The test
+// fails if a request has no matching interaction, or if an interaction is
+// still unused when the test completes.
+func NewReplayer(t testing.TB, path string) *Replayer {
+	t.Helper()
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("qitest: %v", err)
+	}
+	r := &Replayer{
+		t:            t,
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+	t.Cleanup(r.checkUnused)
+	return r
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	jsonBody, text := redactBody(body)
+	normalized := normalizeBody(jsonBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.interactions {
+		if r.used[i] || in.Request.Method != req.Method || in.Request.Path != req.URL.Path {
+			continue
+		}
+		if !bytes.Equal(normalizeBody(in.Request.Body), normalized) || in.Request.BodyText != text {
+			continue
+		}
+		r.used[i] = true
+
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		respBody := []byte(in.Response.Body)
+		if respBody == nil {
+			respBody = []byte(in.Response.BodyText)
+		}
+		return &http.Response{
+			StatusCode: in.Response.StatusCode,
+			Status:     fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(respBody)),
+			Request:    req,
+		}, nil
+	}
+
+	r.t.Errorf("qitest: no recorded interaction for %s %s %s%s", req.Method, req.URL.Path, normalized, text)
+	return nil, fmt.Errorf("qitest: no recorded interaction for %s %s", req.Method, req.URL.Path)
+}
+
+func (r *Replayer) checkUnused() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			r.t.Errorf("qitest: recorded interaction %d was not used: %s %s", i, in.Request.Method, in.Request.Path)
+		}
+	}
+}
+
+// readBody reads and restores a request or response body.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func recordRequestHeader(h http.Header) http.Header {
+	out := make(http.Header)
+	for _, key := range recordedHeaders {
+		if v := h.Values(key); len(v) > 0 {
+			out[key] = append([]string(nil), v...)
+		}
+	}
+	for _, key := range redactedHeaders {
+		if _, ok := out[key]; ok {
+			out.Set(key, Redacted)
+		}
+	}
+	for _, key := range strippedHeaders {
+		out.Del(key)
+	}
+	return out
+}
+
+func recordResponseHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, key := range responseExcluded {
+		out.Del(key)
+	}
+	return out
+}
+
+// redactBody replaces the values of PIIFields in a JSON body. Bodies that
+// are not JSON are returned unchanged as text, so that they are replayed
+// byte for byte.
+func redactBody(body []byte) (json.RawMessage, string) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, string(body)
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, string(body)
+	}
+	data, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil, ""
+	}
+	return data, ""
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if isPIIField(key) && val != nil {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactValue(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return v
+}
+
+func isPIIField(key string) bool {
+	for _, f := range PIIFields {
+		if f == key {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBody re-encodes a JSON body with sorted keys and no whitespace.
+func normalizeBody(body json.RawMessage) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return data
+}
@@ -0,0 +1,219 @@
+package qitest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BynxDev/qi"
+	"github.com/BynxDev/qi/qitest"
+)
+
+func newPaymentServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/payment":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"requestId": "req-1",
+				"paymentId": "pay-1",
+				"status":    "CREATED",
+				"amount":    100,
+				"currency":  "IQD",
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/payment/pay-1/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"requestId": "req-1",
+				"paymentId": "pay-1",
+				"status":    "SUCCESS",
+				"amount":    100,
+				"currency":  "IQD",
+				"details":   map[string]interface{}{"maskedPan": "123456******1234"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":12,"message":"not found"}}`)
+		}
+	}))
+}
+
+func exercise(t *testing.T, client *qi.Client) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := client.CreatePayment(ctx, &qi.CreatePaymentRequest{
+		RequestID:    "req-1",
+		Amount:       100,
+		Currency:     "IQD",
+		CustomerInfo: &qi.CustomerInfo{FirstName: "John", Phone: "9647701234567"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	status, err := client.GetPaymentStatus(ctx, "pay-1")
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if status.Status != qi.PaymentStatusSuccess {
+		t.Errorf("expected status SUCCESS, got %s", status.Status)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := newPaymentServer(t)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "testdata", "payment.json")
+
+	recorder := qitest.NewRecorder(nil)
+	exercise(t, qi.NewClient("terminal-1",
+		qi.WithBaseURL(server.URL),
+		qi.WithBasicAuth("user", "secret"),
+		qi.WithSignature("sig"),
+		qi.WithHTTPClient(&http.Client{Transport: recorder}),
+	))
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"secret", "terminal-1", "John", "9647701234567", "123456******1234", "X-Signature"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("cassette contains %q", leaked)
+		}
+	}
+
+	exercise(t, qi.NewClient("other-terminal",
+		qi.WithBaseURL("http://qi.invalid"),
+		qi.WithHTTPClient(&http.Client{Transport: qitest.NewReplayer(t, path)}),
+	))
+}
+
+// fakeTB records failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+
+func TestReplayerUnmatchedAndUnused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &qitest.Cassette{Interactions: []qitest.Interaction{{
+		Request:  qitest.Request{Method: http.MethodGet, Path: "/payment/pay-1/status"},
+		Response: qitest.Response{StatusCode: http.StatusOK, Body: json.RawMessage(`{"paymentId":"pay-1","status":"SUCCESS"}`)},
+	}}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := &fakeTB{}
+	client := qi.NewClient("terminal-1",
+		qi.WithBaseURL("http://qi.invalid"),
+		qi.WithHTTPClient(&http.Client{Transport: qitest.NewReplayer(tb, path)}),
+	)
+
+	if _, err := client.GetPaymentStatus(context.Background(), "pay-2"); err == nil {
+		t.Error("expected error for unmatched request")
+	}
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "no recorded interaction") {
+		t.Errorf("expected unmatched failure, got %v", tb.errors)
+	}
+
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	if len(tb.errors) != 2 || !strings.Contains(tb.errors[1], "was not used") {
+		t.Errorf("expected unused failure, got %v", tb.errors)
+	}
+}
+
+func TestReplayerMatchesNormalizedBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &qitest.Cassette{Interactions: []qitest.Interaction{{
+		Request: qitest.Request{
+			Method: http.MethodPost,
+			Path:   "/payment",
+			Body:   json.RawMessage(`{ "currency": "IQD", "amount": 100, "requestId": "req-1" }`),
+		},
+		Response: qitest.Response{StatusCode: http.StatusOK, Body: json.RawMessage(`{"paymentId":"pay-1","status":"CREATED"}`)},
+	}}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	client := qi.NewClient("terminal-1",
+		qi.WithBaseURL("http://qi.invalid"),
+		qi.WithHTTPClient(&http.Client{Transport: qitest.NewReplayer(t, path)}),
+	)
+	payment, err := client.CreatePayment(context.Background(), &qi.CreatePaymentRequest{
+		RequestID: "req-1",
+		Amount:    100,
+		Currency:  "IQD",
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if payment.PaymentID != "pay-1" {
+		t.Errorf("expected payment ID pay-1, got %s", payment.PaymentID)
+	}
+}
+
+func TestRecordAndReplayNonJSONBody(t *testing.T) {
+	const page = "<html><body>502 Bad Gateway</body></html>\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "error.json")
+	recorder := qitest.NewRecorder(nil)
+	status := func(client *qi.Client) string {
+		t.Helper()
+		_, err := client.GetPaymentStatus(context.Background(), "pay-1")
+		var apiErr *qi.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+			t.Fatalf("expected a 502 APIError, got %v", err)
+		}
+		return apiErr.Message
+	}
+
+	recorded := status(qi.NewClient("terminal-1",
+		qi.WithBaseURL(server.URL),
+		qi.WithHTTPClient(&http.Client{Transport: recorder}),
+	))
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	replayed := status(qi.NewClient("terminal-1",
+		qi.WithBaseURL("http://qi.invalid"),
+		qi.WithHTTPClient(&http.Client{Transport: qitest.NewReplayer(t, path)}),
+	))
+
+	if recorded != page || replayed != page {
+		t.Errorf("expected the body %q to be replayed unchanged, got %q", page, replayed)
+	}
+}
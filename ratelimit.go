@@ -0,0 +1,272 @@
+package qi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimitMinFactor is the default fraction of the configured
+	// rate below which the limiter does not adapt.
+	DefaultRateLimitMinFactor = 0.1
+	// DefaultRateLimitRecovery is the default time after which an adapted
+	// rate recovers to the configured rate.
+	DefaultRateLimitRecovery = time.Minute
+)
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Rate is the number of requests per second. Zero means no limit.
+	Rate float64
+	// Burst is the number of requests that can be made at once. Zero uses
+	// the rate rounded up.
+	Burst int
+}
+
+// RateLimitConfig configures WithRateLimit. Every terminal has its own
+// buckets.
+type RateLimitConfig struct {
+	// Terminal limits all calls made with a terminal.
+	Terminal RateLimit
+	// Operations additionally limits calls to single operations, keyed by
+	// the Operation constants.
+	Operations map[string]RateLimit
+	// MinFactor is the lowest fraction of the configured rate the limiter
+	// adapts down to. Zero uses DefaultRateLimitMinFactor.
+	MinFactor float64
+	// Recovery is the time after which an adapted rate is back to the
+	// configured rate, increasing linearly. Zero uses
+	// DefaultRateLimitRecovery.
+	Recovery time.Duration
+}
+
+// RateLimitState is a snapshot of a token bucket, for metrics.
+type RateLimitState struct {
+	Terminal string
+	// Operation is empty for the bucket limiting all calls of the terminal.
+	Operation string
+	// Rate is the current rate, lower than ConfiguredRate after the gateway
+	// reported a limit violation.
+	Rate           float64
+	ConfiguredRate float64
+	// Tokens is the number of requests that can be made without waiting.
+	Tokens float64
+	// BlockedUntil is set while a Retry-After delay is being honoured.
+	BlockedUntil time.Time
+}
+
+// WithRateLimit limits the rate of calls made by the client. Calls wait for
+// a token, or until their context is done. When the gateway responds with
+// LIMIT_VIOLATION or 429 Too Many Requests, the rate of the buckets used by
+// the call is halved and recovers over time, and calls are held back until
+// the Retry-After delay, if any, has passed.
+func WithRateLimit(config RateLimitConfig) ClientOption {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(config)
+	}
+}
+
+// RateLimitState returns the state of the rate limiter buckets, sorted by
+// terminal and operation. It returns nil without WithRateLimit.
+func (c *Client) RateLimitState() []RateLimitState {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.state()
+}
+
+type bucketKey struct {
+	terminal  string
+	operation string
+}
+
+type rateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.MinFactor <= 0 {
+		config.MinFactor = DefaultRateLimitMinFactor
+	}
+	if config.Recovery <= 0 {
+		config.Recovery = DefaultRateLimitRecovery
+	}
+	return &rateLimiter{config: config, now: time.Now, buckets: make(map[bucketKey]*bucket)}
+}
+
+// bucketsFor returns the buckets limiting operation on terminal, creating
+// them on first use. The caller must hold l.mu.
+func (l *rateLimiter) bucketsFor(terminal, operation string) []*bucket {
+	var buckets []*bucket
+	add := func(key bucketKey, limit RateLimit) {
+		if limit.Rate <= 0 {
+			return
+		}
+		b, ok := l.buckets[key]
+		if !ok {
+			b = newBucket(limit, l.now())
+			l.buckets[key] = b
+		}
+		buckets = append(buckets, b)
+	}
+	add(bucketKey{terminal, ""}, l.config.Terminal)
+	if limit, ok := l.config.Operations[operation]; ok {
+		add(bucketKey{terminal, operation}, limit)
+	}
+	return buckets
+}
+
+// wait blocks until every bucket for the call has a token and takes them.
+func (l *rateLimiter) wait(ctx context.Context, terminal, operation string) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		// A call whose context is done does not take a token.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		now := l.now()
+		buckets := l.bucketsFor(terminal, operation)
+		var delay time.Duration
+		for _, b := range buckets {
+			b.refill(now, l.config)
+			if d := b.delay(now); d > delay {
+				delay = d
+			}
+		}
+		if delay == 0 {
+			for _, b := range buckets {
+				b.tokens--
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// observe adapts the buckets for the call to its outcome.
+func (l *rateLimiter) observe(terminal, operation string, err error) {
+	if l == nil {
+		return
+	}
+	limited, retryAfter := isRateLimited(err)
+	if !limited {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, b := range l.bucketsFor(terminal, operation) {
+		b.refill(now, l.config)
+		b.rate = math.Max(b.rate/2, b.limit.Rate*l.config.MinFactor)
+		b.adapted = now
+		b.tokens = math.Min(b.tokens, 0)
+		if until := now.Add(retryAfter); until.After(b.blockedUntil) {
+			b.blockedUntil = until
+		}
+	}
+}
+
+func (l *rateLimiter) state() []RateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	states := make([]RateLimitState, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		b.refill(now, l.config)
+		s := RateLimitState{
+			Terminal:       key.terminal,
+			Operation:      key.operation,
+			Rate:           b.rate,
+			ConfiguredRate: b.limit.Rate,
+			Tokens:         b.tokens,
+		}
+		if b.blockedUntil.After(now) {
+			s.BlockedUntil = b.blockedUntil
+		}
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Terminal != states[j].Terminal {
+			return states[i].Terminal < states[j].Terminal
+		}
+		return states[i].Operation < states[j].Operation
+	})
+	return states
+}
+
+type bucket struct {
+	limit        RateLimit
+	burst        float64
+	rate         float64
+	tokens       float64
+	last         time.Time
+	adapted      time.Time
+	blockedUntil time.Time
+}
+
+func newBucket(limit RateLimit, now time.Time) *bucket {
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(limit.Rate)
+	}
+	return &bucket{limit: limit, burst: burst, rate: limit.Rate, tokens: burst, last: now}
+}
+
+// refill adds the tokens accumulated since the last refill and recovers an
+// adapted rate.
+func (b *bucket) refill(now time.Time, config RateLimitConfig) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.rate < b.limit.Rate {
+		since := now.Sub(b.adapted).Seconds() / config.Recovery.Seconds()
+		b.rate = math.Min(b.limit.Rate, b.rate+since*b.limit.Rate)
+		b.adapted = now
+	}
+}
+
+// delay returns how long to wait for a token.
+func (b *bucket) delay(now time.Time) time.Duration {
+	if b.blockedUntil.After(now) {
+		return b.blockedUntil.Sub(now)
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// isRateLimited returns true if err reports that the gateway's rate limit
+// was exceeded, with the Retry-After delay if there is one.
+func isRateLimited(err error) (bool, time.Duration) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false, 0
+	}
+	limited := apiErr.StatusCode == http.StatusTooManyRequests ||
+		(apiErr.Err != nil && apiErr.Err.Error.Code == ErrorCodeLimitViolation)
+	if !limited {
+		return false, 0
+	}
+	return true, apiErr.RetryAfter()
+}
@@ -0,0 +1,121 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"paymentId":"test-payment-id","status":"SUCCESS"}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithRateLimit(qi.RateLimitConfig{
+			Terminal:   qi.RateLimit{Rate: 100, Burst: 10},
+			Operations: map[string]qi.RateLimit{qi.OperationGetPaymentStatus: {Rate: 20, Burst: 1}},
+		}),
+	)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected calls to be spaced out by the operation limit, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded while waiting for a token, got %v", err)
+	}
+
+	state := client.RateLimitState()
+	if len(state) != 2 || state[0].Operation != "" || state[1].Operation != qi.OperationGetPaymentStatus {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if state[0].Terminal != "test-terminal" || state[0].Rate != 100 {
+		t.Errorf("unexpected terminal bucket %+v", state[0])
+	}
+}
+
+func TestRateLimitCanceledCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"paymentId":"test-payment-id","status":"SUCCESS"}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithRateLimit(qi.RateLimitConfig{Terminal: qi.RateLimit{Rate: 1, Burst: 1}}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+
+	// The canceled call must not have taken the only token.
+	start := time.Now()
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the token to be available, waited %v", elapsed)
+	}
+}
+
+func TestRateLimitAdapts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":28,"message":"LIMIT_VIOLATION"}}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithRateLimit(qi.RateLimitConfig{Terminal: qi.RateLimit{Rate: 50}}),
+	)
+
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	state := client.RateLimitState()
+	if len(state) != 1 || state[0].Rate >= 50 || state[0].Rate < 25 || state[0].ConfiguredRate != 50 {
+		t.Errorf("expected rate to be halved, got %+v", state)
+	}
+}
+
+func TestAPIErrorRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+	err := &qi.APIError{StatusCode: http.StatusTooManyRequests, Meta: &qi.ResponseMeta{Header: header}}
+	if got := err.RetryAfter(); got != 2*time.Second {
+		t.Errorf("expected 2s, got %v", got)
+	}
+
+	header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got := err.RetryAfter(); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("expected about an hour, got %v", got)
+	}
+
+	if got := (&qi.APIError{StatusCode: http.StatusTooManyRequests}).RetryAfter(); got != 0 {
+		t.Errorf("expected no delay without a response, got %v", got)
+	}
+}
@@ -0,0 +1,250 @@
+package qi
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rawPayload retains the JSON a response was decoded from.
+type rawPayload struct {
+	raw json.RawMessage
+	typ reflect.Type
+}
+
+// RawJSON returns the JSON the response was decoded from.
+func (r rawPayload) RawJSON() json.RawMessage {
+	return r.raw
+}
+
+// UnknownFields returns the fields of the response that have no
+// corresponding struct field. They are keyed by JSON name, or by their path
+// for fields of nested objects, e.g. "details.fee" or "cancels[0].reason".
+//
+// The fields are found by decoding RawJSON again on each call, so responses
+// are only decoded once unless they are asked for or an unknown hook is set.
+func (r rawPayload) UnknownFields() map[string]json.RawMessage {
+	if r.raw == nil || r.typ == nil {
+		return nil
+	}
+	unknown := make(map[string]json.RawMessage)
+	// RawJSON was already decoded into the response, so it is valid.
+	_ = collectUnknown(unknown, r.raw, r.typ, "")
+	if len(unknown) == 0 {
+		return nil
+	}
+	return unknown
+}
+
+// capture retains a copy of data, which typ was decoded from.
+func (r *rawPayload) capture(data []byte, typ reflect.Type) {
+	r.raw = append(json.RawMessage(nil), data...)
+	r.typ = typ
+}
+
+// collectUnknown adds the fields of the JSON object data unknown to typ, and
+// those of the nested objects, to unknown, prefixing their names with prefix.
+func collectUnknown(unknown map[string]json.RawMessage, data []byte, typ reflect.Type, prefix string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	known := knownFields(typ)
+	for name, value := range fields {
+		fieldType, ok := known[name]
+		if !ok {
+			unknown[prefix+name] = value
+			continue
+		}
+		if err := collectNested(unknown, value, fieldType, prefix+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// collectNested adds the unknown fields of value to unknown if typ is a
+// struct or a slice of structs.
+func collectNested(unknown map[string]json.RawMessage, value json.RawMessage, typ reflect.Type, path string) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(unmarshalerType) {
+		return nil
+	}
+
+	switch {
+	case typ.Kind() == reflect.Struct:
+		return collectUnknown(unknown, value, typ, path+".")
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Struct:
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			return err
+		}
+		for i, item := range items {
+			if err := collectNested(unknown, item, typ.Elem(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var knownFieldsCache sync.Map
+
+// knownFields returns the types of the fields of typ, keyed by JSON name.
+func knownFields(typ reflect.Type) map[string]reflect.Type {
+	if known, ok := knownFieldsCache.Load(typ); ok {
+		return known.(map[string]reflect.Type)
+	}
+	known := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		known[name] = f.Type
+	}
+	knownFieldsCache.Store(typ, known)
+	return known
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	type payment Payment
+	if err := json.Unmarshal(data, (*payment)(p)); err != nil {
+		return err
+	}
+	p.capture(data, reflect.TypeOf(*p))
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *PaymentStatusResponse) UnmarshalJSON(data []byte) error {
+	type paymentStatusResponse PaymentStatusResponse
+	if err := json.Unmarshal(data, (*paymentStatusResponse)(s)); err != nil {
+		return err
+	}
+	s.capture(data, reflect.TypeOf(*s))
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *PaymentCancelResponse) UnmarshalJSON(data []byte) error {
+	type paymentCancelResponse PaymentCancelResponse
+	if err := json.Unmarshal(data, (*paymentCancelResponse)(r)); err != nil {
+		return err
+	}
+	r.capture(data, reflect.TypeOf(*r))
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *Refund) UnmarshalJSON(data []byte) error {
+	type refund Refund
+	if err := json.Unmarshal(data, (*refund)(r)); err != nil {
+		return err
+	}
+	r.capture(data, reflect.TypeOf(*r))
+	return nil
+}
+
+// Unknown describes a value in a response that this version of the client
+// does not know about.
+type Unknown struct {
+	// Type is the name of the response or enum type, e.g. "Payment" or
+	// "PaymentStatus".
+	Type string
+	// Field is the JSON name of the field, or its path in the response for
+	// fields of nested objects, e.g. "details.fee".
+	Field string
+	// Value is the unknown enum value, or the raw JSON of an unknown field.
+	Value string
+}
+
+// UnknownFunc is called for every unknown enum value or field seen in a
+// response.
+type UnknownFunc func(ctx context.Context, u Unknown)
+
+// WithUnknownHook sets a function that is notified of unknown enum values and
+// fields in responses, to detect changes to the API.
+func WithUnknownHook(fn UnknownFunc) ClientOption {
+	return func(c *Client) {
+		c.unknownHook = fn
+	}
+}
+
+// reportUnknown notifies the unknown hook of unknown values in a response.
+func (c *Client) reportUnknown(ctx context.Context, result interface{}) {
+	if c.unknownHook == nil {
+		return
+	}
+
+	var typeName string
+	var fields map[string]json.RawMessage
+	var status PaymentStatus
+	var currency Currency
+	var paymentType PaymentType
+	var details *PaymentDetails
+	switch r := result.(type) {
+	case *Payment:
+		typeName, fields, status, currency = "Payment", r.UnknownFields(), r.Status, r.Currency
+	case *PaymentStatusResponse:
+		typeName, fields, status, currency, details = "PaymentStatusResponse", r.UnknownFields(), r.Status, r.Currency, r.Details
+		paymentType = r.PaymentType
+	case *PaymentCancelResponse:
+		typeName, fields, status, currency = "PaymentCancelResponse", r.UnknownFields(), r.Status, r.Currency
+	case *Refund:
+		typeName, fields, currency, details = "Refund", r.UnknownFields(), r.Currency, r.Details
+		if r.Status != "" && !r.Status.IsKnown() {
+			c.unknownHook(ctx, Unknown{Type: "RefundStatus", Field: "status", Value: string(r.Status)})
+		}
+	case *Transfer:
+		typeName, fields, currency, details = "Transfer", r.UnknownFields(), r.Currency, r.Details
+		if r.Status != "" && !r.Status.IsKnown() {
+			c.unknownHook(ctx, Unknown{Type: "TransferStatus", Field: "status", Value: string(r.Status)})
+		}
+	default:
+		return
+	}
+
+	if status != "" && !status.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "PaymentStatus", Field: "status", Value: string(status)})
+	}
+	if currency != "" && !currency.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "Currency", Field: "currency", Value: string(currency)})
+	}
+	if paymentType != "" && !paymentType.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "PaymentType", Field: "paymentType", Value: string(paymentType)})
+	}
+	if details != nil && details.PaymentSystem != "" && !details.PaymentSystem.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "PaymentSystem", Field: "paymentSystem", Value: string(details.PaymentSystem)})
+	}
+	for name, value := range fields {
+		c.unknownHook(ctx, Unknown{Type: typeName, Field: name, Value: string(value)})
+	}
+}
+
+// reportUnknownError notifies the unknown hook of unknown error codes.
+func (c *Client) reportUnknownError(ctx context.Context, apiErr *Error) {
+	if c.unknownHook == nil {
+		return
+	}
+	if !apiErr.Error.Code.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "ErrorCode", Field: "code", Value: strconv.Itoa(int(apiErr.Error.Code))})
+	}
+	if apiErr.Error.Message != "" && !apiErr.Error.Message.IsKnown() {
+		c.unknownHook(ctx, Unknown{Type: "ErrorMessage", Field: "message", Value: string(apiErr.Error.Message)})
+	}
+}
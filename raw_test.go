@@ -0,0 +1,101 @@
+package qi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestRawJSONAndUnknownFields(t *testing.T) {
+	body := `{"paymentId":"test-payment-id","status":"ON_HOLD","amount":100,"currency":"IQD","fee":0.5,"paymentType":"WALLET",` +
+		`"details":{"paymentSystem":"UNION_PAY","authDate":"2024-08-04T15:34:33","network":"local"},` +
+		`"cancels":[{"amount":10,"successfully":true},{"amount":5,"reason":"customer"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	var seen []string
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithUnknownHook(func(ctx context.Context, u qi.Unknown) {
+			seen = append(seen, u.Type+"."+u.Field+"="+u.Value)
+		}),
+	)
+
+	status, err := client.GetPaymentStatus(context.Background(), "test-payment-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(status.RawJSON()) != body {
+		t.Errorf("expected raw JSON %s, got %s", body, status.RawJSON())
+	}
+	if fee := string(status.UnknownFields()["fee"]); fee != "0.5" {
+		t.Errorf("expected unknown field fee=0.5, got %q", fee)
+	}
+	if status.Status.IsKnown() {
+		t.Errorf("expected status %s to be unknown", status.Status)
+	}
+
+	sort.Strings(seen)
+	want := []string{
+		"PaymentStatus.status=ON_HOLD",
+		`PaymentStatusResponse.cancels[1].reason="customer"`,
+		`PaymentStatusResponse.details.network="local"`,
+		"PaymentStatusResponse.fee=0.5",
+		"PaymentSystem.paymentSystem=UNION_PAY",
+		"PaymentType.paymentType=WALLET",
+	}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("expected hook calls %v, got %v", want, seen)
+	}
+}
+
+func TestUnknownErrorCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":99,"message":"NEW_ERROR"}}`)
+	}))
+	defer server.Close()
+
+	var seen []qi.Unknown
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithUnknownHook(func(ctx context.Context, u qi.Unknown) {
+			seen = append(seen, u)
+		}),
+	)
+
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(seen) != 2 || seen[0].Type != "ErrorCode" || seen[0].Value != "99" || seen[1].Value != "NEW_ERROR" {
+		t.Errorf("expected unknown error code and message, got %+v", seen)
+	}
+}
+
+func TestIsKnown(t *testing.T) {
+	if !qi.PaymentStatusExpired.IsKnown() || qi.PaymentStatus("ON_HOLD").IsKnown() {
+		t.Error("unexpected PaymentStatus.IsKnown result")
+	}
+	if !qi.PaymentTypeCard.IsKnown() || qi.PaymentType("WALLET").IsKnown() {
+		t.Error("unexpected PaymentType.IsKnown result")
+	}
+	if !qi.RefundStatusProcessing.IsKnown() || qi.RefundStatus("PENDING").IsKnown() {
+		t.Error("unexpected RefundStatus.IsKnown result")
+	}
+	if !qi.ErrorCodeInvalidTokenType.IsKnown() || qi.ErrorCode(25).IsKnown() || qi.ErrorCode(36).IsKnown() {
+		t.Error("unexpected ErrorCode.IsKnown result")
+	}
+	if !qi.ErrorMessagePaymentNotFound.IsKnown() || qi.ErrorMessage("NEW_ERROR").IsKnown() {
+		t.Error("unexpected ErrorMessage.IsKnown result")
+	}
+}
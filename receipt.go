@@ -0,0 +1,121 @@
+package qi
+
+import (
+	"fmt"
+)
+
+// VAT returns the VAT included in the given amount. Item amounts always
+// include VAT: VAT10 and VAT110 extract 10/110 of the amount, VAT20 and
+// VAT120 extract 20/120. The calculated VAT110 and VAT120 rates differ from
+// VAT10 and VAT20 only in how the gateway reports them.
+func (t ItemTax) VAT(amount float64) float64 {
+	switch t {
+	case ItemTaxVAT10, ItemTaxVAT110:
+		return RoundAmount(amount * 10 / 110)
+	case ItemTaxVAT20, ItemTaxVAT120:
+		return RoundAmount(amount * 20 / 120)
+	}
+	return 0
+}
+
+// VAT returns the VAT included in the item amount.
+func (i PaymentItem) VAT() float64 {
+	return i.Tax.VAT(i.Amount)
+}
+
+// Total returns the amount payable for all items, including VAT.
+func (info *ItemsInfo) Total() float64 {
+	var total int64
+	for _, item := range info.Items {
+		total += AmountUnits(item.Amount)
+	}
+	return AmountFromUnits(total)
+}
+
+// VAT returns the VAT included in all items.
+func (info *ItemsInfo) VAT() float64 {
+	var total int64
+	for _, item := range info.Items {
+		total += AmountUnits(item.VAT())
+	}
+	return AmountFromUnits(total)
+}
+
+// Validate checks that every item is well formed and that the items add up
+// to the payment amount.
+func (info *ItemsInfo) Validate(amount float64) error {
+	for i, item := range info.Items {
+		field := fmt.Sprintf("itemsInfo.items[%d].", i)
+		if item.PaymentMethod != "" && !item.PaymentMethod.IsKnown() {
+			return &ValidationError{Field: field + "paymentMethod", Reason: fmt.Sprintf("unknown payment method %q", item.PaymentMethod)}
+		}
+		if item.PaymentObject != "" && !item.PaymentObject.IsKnown() {
+			return &ValidationError{Field: field + "paymentObject", Reason: fmt.Sprintf("unknown payment object %q", item.PaymentObject)}
+		}
+		if item.Tax != "" && !item.Tax.IsKnown() {
+			return &ValidationError{Field: field + "tax", Reason: fmt.Sprintf("unknown VAT rate %q", item.Tax)}
+		}
+		if item.Quantity <= 0 {
+			return &ValidationError{
+				Field:  field + "quantity",
+				Reason: "must be greater than zero",
+			}
+		}
+		if AmountUnits(item.Amount) != AmountUnits(item.Price*item.Quantity) {
+			return &ValidationError{
+				Field:  field + "amount",
+				Reason: fmt.Sprintf("%.2f does not equal price times quantity", item.Amount),
+			}
+		}
+	}
+
+	if total := info.Total(); AmountUnits(total) != AmountUnits(amount) {
+		return &ValidationError{
+			Field:  "itemsInfo",
+			Reason: fmt.Sprintf("items total %.2f does not equal payment amount %.2f", total, amount),
+		}
+	}
+	return nil
+}
+
+// ReceiptBuilder builds an ItemsInfo from purchase positions.
+type ReceiptBuilder struct {
+	description string
+	items       []PaymentItem
+}
+
+// NewReceiptBuilder creates a new ReceiptBuilder with the given description.
+func NewReceiptBuilder(description string) *ReceiptBuilder {
+	return &ReceiptBuilder{description: description}
+}
+
+// AddItem adds an item to the receipt. The item amount is computed from its
+// price and quantity.
+func (b *ReceiptBuilder) AddItem(item PaymentItem) *ReceiptBuilder {
+	item.Amount = RoundAmount(item.Price * item.Quantity)
+	b.items = append(b.items, item)
+	return b
+}
+
+// Total returns the amount payable for all items added so far.
+func (b *ReceiptBuilder) Total() float64 {
+	return b.info().Total()
+}
+
+// Build returns the ItemsInfo, checking that the items add up to amount.
+func (b *ReceiptBuilder) Build(amount float64) (*ItemsInfo, error) {
+	info := b.info()
+	if err := info.Validate(amount); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (b *ReceiptBuilder) info() *ItemsInfo {
+	items := make([]PaymentItem, len(b.items))
+	copy(items, b.items)
+	return &ItemsInfo{
+		Description: b.description,
+		Items:       items,
+	}
+}
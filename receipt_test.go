@@ -0,0 +1,158 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestItemTaxVAT(t *testing.T) {
+	tests := []struct {
+		tax    qi.ItemTax
+		amount float64
+		want   float64
+	}{
+		{qi.ItemTaxNone, 100, 0},
+		{qi.ItemTaxVAT0, 100, 0},
+		{qi.ItemTaxVAT10, 110, 10},
+		{qi.ItemTaxVAT20, 120, 20},
+		{qi.ItemTaxVAT20, 10, 1.67},
+		{qi.ItemTaxVAT110, 110, 10},
+		{qi.ItemTaxVAT120, 120, 20},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tax.VAT(tt.amount); got != tt.want {
+			t.Errorf("%s.VAT(%v) = %v, want %v", tt.tax, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestReceiptBuilder(t *testing.T) {
+	b := qi.NewReceiptBuilder("Groceries").
+		AddItem(qi.PaymentItem{Name: "Apples", Price: 140.2, Quantity: 0.5, Tax: qi.ItemTaxVAT120}).
+		AddItem(qi.PaymentItem{Name: "Delivery", Price: 10, Quantity: 1, Tax: qi.ItemTaxVAT20})
+
+	if got := b.Total(); got != 80.1 {
+		t.Fatalf("expected total 80.1, got %v", got)
+	}
+
+	info, err := b.Build(80.1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.Items[0].Amount != 70.1 {
+		t.Errorf("expected item amount 70.1, got %v", info.Items[0].Amount)
+	}
+
+	var sum float64
+	for _, item := range info.Items {
+		sum += item.Amount
+	}
+	if sum != 80.1 {
+		t.Errorf("expected item amounts to add up to 80.1, got %v", sum)
+	}
+
+	if info.VAT() != 13.35 {
+		t.Errorf("expected VAT 13.35, got %v", info.VAT())
+	}
+
+	_, err = b.Build(80)
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestCreatePaymentItemsMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not be sent")
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	_, err := client.CreatePayment(context.Background(), &qi.CreatePaymentRequest{
+		RequestID: "test-request-id",
+		Amount:    100,
+		ItemsInfo: &qi.ItemsInfo{
+			Items: []qi.PaymentItem{{Name: "Apples", Price: 50, Quantity: 1, Amount: 50}},
+		},
+	})
+
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestItemsInfoUnknownEnum(t *testing.T) {
+	info := &qi.ItemsInfo{Items: []qi.PaymentItem{
+		{Name: "Apples", Price: 50, Quantity: 1, Amount: 50, Tax: qi.ItemTaxVAT10},
+		{Name: "Pears", Price: 50, Quantity: 1, Amount: 50, Tax: "VAT15"},
+	}}
+
+	var validationErr *qi.ValidationError
+	if err := info.Validate(100); !errors.As(err, &validationErr) || validationErr.Field != "itemsInfo.items[1].tax" {
+		t.Fatalf("expected ValidationError for itemsInfo.items[1].tax, got %v", err)
+	}
+}
+
+func TestCreatePaymentItemsInfo(t *testing.T) {
+	received := make(chan *qi.CreatePaymentRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req qi.CreatePaymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- &req
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.Payment{PaymentID: "test-payment-id"})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	info, err := qi.NewReceiptBuilder("Order").
+		AddItem(qi.PaymentItem{Name: "Book", Price: 25, Quantity: 2}).
+		Build(50)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = client.CreatePayment(context.Background(), &qi.CreatePaymentRequest{
+		RequestID: "test-request-id",
+		Amount:    50,
+		Currency:  "IQD",
+		ItemsInfo: info,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-received
+	if req.ItemsInfo == nil || len(req.ItemsInfo.Items) != 1 {
+		t.Errorf("expected itemsInfo with one item")
+	}
+}
+
+func TestCreatePaymentNilRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"paymentId":"test-payment-id"}`))
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	if _, err := client.CreatePayment(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package reconcile compares locally stored orders with their state on the
+// QiCard Payment Gateway.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/BynxDev/qi"
+	"github.com/BynxDev/qi/internal/interval"
+)
+
+// DefaultConcurrency is the default number of concurrent status lookups.
+const DefaultConcurrency = 4
+
+// Record is a locally stored order to be checked against the gateway.
+type Record struct {
+	RequestID string
+	Amount    float64
+	Status    qi.PaymentStatus
+	Canceled  bool
+	Refunded  float64
+}
+
+// Iterator iterates over local records.
+type Iterator interface {
+	// Next advances to the next record, returning false when there are no
+	// more records or an error occurred.
+	Next(ctx context.Context) bool
+	// Record returns the current record.
+	Record() Record
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+}
+
+// SliceIterator returns an Iterator over records.
+func SliceIterator(records []Record) Iterator {
+	return &sliceIterator{records: records, pos: -1}
+}
+
+type sliceIterator struct {
+	records []Record
+	pos     int
+}
+
+func (it *sliceIterator) Next(ctx context.Context) bool {
+	if it.pos+1 >= len(it.records) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *sliceIterator) Record() Record { return it.records[it.pos] }
+
+func (it *sliceIterator) Err() error { return nil }
+
+// StatusGetter retrieves a payment status by request ID. It is implemented by
+// *qi.Client.
+type StatusGetter interface {
+	GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error)
+}
+
+// Reconciler looks up local records on the gateway and reports discrepancies.
+type Reconciler struct {
+	client      StatusGetter
+	refunds     qi.RefundSource
+	concurrency int
+	rateLimit   float64
+}
+
+// Option is a function that configures a Reconciler.
+type Option func(*Reconciler)
+
+// WithConcurrency sets the maximum number of concurrent status lookups.
+func WithConcurrency(n int) Option {
+	return func(r *Reconciler) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// WithRateLimit limits status lookups to perSecond requests per second.
+func WithRateLimit(perSecond float64) Option {
+	return func(r *Reconciler) {
+		r.rateLimit = perSecond
+	}
+}
+
+// WithRefunds sets the source of known refunds used to detect unexpected
+// refunds. Without it, refunds are not checked.
+func WithRefunds(refunds qi.RefundSource) Option {
+	return func(r *Reconciler) {
+		r.refunds = refunds
+	}
+}
+
+// New creates a new Reconciler.
+func New(client StatusGetter, opts ...Option) *Reconciler {
+	r := &Reconciler{
+		client:      client,
+		concurrency: DefaultConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Run checks every record returned by it and returns the discrepancies found,
+// in the order of the records. Lookup failures are reported as discrepancies
+// of kind KindError; Run itself only fails if the iterator or ctx does.
+func (r *Reconciler) Run(ctx context.Context, it Iterator) (*Report, error) {
+	return r.RunWithGateway(ctx, it, nil)
+}
+
+// RunWithGateway is like Run, and also reports the payments returned by
+// gateway that have no local record, as discrepancies of kind KindUntracked
+// after those of the local records. The API cannot list payments, so gateway
+// iterates over payments known from another source, such as notifications or
+// the acquirer's settlement report.
+func (r *Reconciler) RunWithGateway(ctx context.Context, it Iterator, gateway Iterator) (*Report, error) {
+	type job struct {
+		index  int
+		record Record
+	}
+
+	jobs := make(chan job)
+	var (
+		mu     sync.Mutex
+		found  []indexedDiscrepancy
+		wg     sync.WaitGroup
+		limit  = interval.NewLimiter(r.rateLimit)
+		report = &Report{}
+	)
+
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := limit.Wait(ctx); err != nil {
+					continue
+				}
+				for _, d := range r.check(ctx, j.record) {
+					mu.Lock()
+					found = append(found, indexedDiscrepancy{j.index, d})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	local := make(map[string]bool)
+	index := 0
+	for it.Next(ctx) {
+		record := it.Record()
+		if gateway != nil {
+			local[record.RequestID] = true
+		}
+		select {
+		case jobs <- job{index, record}:
+			index++
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(found, func(a, b int) bool { return found[a].index < found[b].index })
+	report.Checked = index
+	for _, f := range found {
+		report.Discrepancies = append(report.Discrepancies, f.Discrepancy)
+	}
+
+	if gateway == nil {
+		return report, nil
+	}
+	for gateway.Next(ctx) {
+		record := gateway.Record()
+		report.GatewayChecked++
+		if !local[record.RequestID] {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind:         KindUntracked,
+				RequestID:    record.RequestID,
+				ActualAmount: record.Amount,
+				ActualStatus: record.Status,
+			})
+		}
+	}
+	if err := gateway.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type indexedDiscrepancy struct {
+	index int
+	Discrepancy
+}
+
+// check compares a single record with its gateway state.
+func (r *Reconciler) check(ctx context.Context, record Record) []Discrepancy {
+	base := Discrepancy{
+		RequestID:      record.RequestID,
+		ExpectedAmount: record.Amount,
+		ExpectedStatus: record.Status,
+	}
+
+	status, err := r.client.GetPaymentStatusByRequest(ctx, record.RequestID)
+	if err != nil {
+		d := base
+		var apiErr *qi.APIError
+		if errors.As(err, &apiErr) && apiErr.IsNotFound() {
+			d.Kind = KindMissing
+		} else {
+			d.Kind = KindError
+		}
+		d.Detail = err.Error()
+		return []Discrepancy{d}
+	}
+
+	base.PaymentID = status.PaymentID
+	base.ActualAmount = status.Amount
+	base.ActualStatus = status.Status
+
+	var result []Discrepancy
+	add := func(kind Kind, detail string) {
+		d := base
+		d.Kind = kind
+		d.Detail = detail
+		result = append(result, d)
+	}
+
+	if !sameAmount(record.Amount, status.Amount) {
+		add(KindAmountMismatch, "")
+	}
+	if record.Status != "" && record.Status != status.Status {
+		add(KindStatusMismatch, "")
+	}
+
+	var refunds []qi.Refund
+	if r.refunds != nil && status.PaymentID != "" {
+		refunds, err = r.refunds.PaymentRefunds(ctx, status.PaymentID)
+		if err != nil {
+			add(KindError, err.Error())
+			return result
+		}
+	}
+	balance := qi.NewPaymentBalance(status, refunds)
+
+	canceled := status.Canceled || balance.Cancelled > 0
+	switch {
+	case !record.Canceled && canceled:
+		add(KindUnexpectedCancel, "")
+	case record.Canceled && !canceled:
+		add(KindMissingCancel, "")
+	}
+	if r.refunds != nil && !sameAmount(record.Refunded, balance.Refunded) {
+		add(KindUnexpectedRefund, "")
+	}
+
+	return result
+}
+
+func sameAmount(a, b float64) bool {
+	return qi.AmountUnits(a) == qi.AmountUnits(b)
+}
@@ -0,0 +1,118 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/BynxDev/qi"
+	"github.com/BynxDev/qi/reconcile"
+)
+
+type fakeGateway map[string]*qi.PaymentStatusResponse
+
+func (g fakeGateway) GetPaymentStatusByRequest(ctx context.Context, requestID string, opts ...qi.CallOption) (*qi.PaymentStatusResponse, error) {
+	status, ok := g[requestID]
+	if !ok {
+		return nil, &qi.APIError{
+			StatusCode: 400,
+			Err: &qi.Error{Error: qi.ErrorDetails{
+				Code:    qi.ErrorCodePaymentNotFound,
+				Message: qi.ErrorMessagePaymentNotFound,
+			}},
+		}
+	}
+	return status, nil
+}
+
+func TestReconciler(t *testing.T) {
+	gateway := fakeGateway{
+		"ok":       {PaymentID: "p1", Status: qi.PaymentStatusSuccess, Amount: 100},
+		"amount":   {PaymentID: "p2", Status: qi.PaymentStatusSuccess, Amount: 90},
+		"status":   {PaymentID: "p3", Status: qi.PaymentStatusFailed, Amount: 100},
+		"canceled": {PaymentID: "p4", Status: qi.PaymentStatusSuccess, Amount: 100, Canceled: true},
+		"active":   {PaymentID: "p5", Status: qi.PaymentStatusSuccess, Amount: 100},
+	}
+
+	records := []reconcile.Record{
+		{RequestID: "ok", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "missing", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "amount", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "status", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "canceled", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "active", Amount: 100, Status: qi.PaymentStatusSuccess, Canceled: true},
+	}
+
+	r := reconcile.New(gateway, reconcile.WithConcurrency(3), reconcile.WithRateLimit(1000))
+	report, err := r.Run(context.Background(), reconcile.SliceIterator(records))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Checked != len(records) {
+		t.Errorf("expected %d checked, got %d", len(records), report.Checked)
+	}
+
+	want := []reconcile.Kind{
+		reconcile.KindMissing,
+		reconcile.KindAmountMismatch,
+		reconcile.KindStatusMismatch,
+		reconcile.KindUnexpectedCancel,
+		reconcile.KindMissingCancel,
+	}
+	if len(report.Discrepancies) != len(want) {
+		t.Fatalf("expected %d discrepancies, got %+v", len(want), report.Discrepancies)
+	}
+	for i, kind := range want {
+		if report.Discrepancies[i].Kind != kind {
+			t.Errorf("discrepancy %d: expected %s, got %s", i, kind, report.Discrepancies[i].Kind)
+		}
+	}
+
+	var jsonl bytes.Buffer
+	if err := report.WriteJSONLines(&jsonl); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(jsonl.String(), "\n"); n != len(want) {
+		t.Errorf("expected %d JSON lines, got %d", len(want), n)
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(csv.String(), "kind,requestId,") {
+		t.Errorf("expected CSV header, got %q", csv.String())
+	}
+}
+
+func TestReconcilerGateway(t *testing.T) {
+	gateway := fakeGateway{
+		"ok": {PaymentID: "p1", Status: qi.PaymentStatusSuccess, Amount: 100},
+	}
+	records := []reconcile.Record{
+		{RequestID: "ok", Amount: 100, Status: qi.PaymentStatusSuccess},
+	}
+	// Payments known to the gateway, e.g. from notifications.
+	known := []reconcile.Record{
+		{RequestID: "ok", Amount: 100, Status: qi.PaymentStatusSuccess},
+		{RequestID: "untracked", Amount: 50, Status: qi.PaymentStatusSuccess},
+	}
+
+	r := reconcile.New(gateway)
+	report, err := r.RunWithGateway(context.Background(), reconcile.SliceIterator(records), reconcile.SliceIterator(known))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Checked != 1 || report.GatewayChecked != 2 {
+		t.Errorf("expected 1 local and 2 gateway payments checked, got %d and %d", report.Checked, report.GatewayChecked)
+	}
+	if len(report.Discrepancies) != 1 {
+		t.Fatalf("expected one discrepancy, got %+v", report.Discrepancies)
+	}
+	d := report.Discrepancies[0]
+	if d.Kind != reconcile.KindUntracked || d.RequestID != "untracked" || d.ActualAmount != 50 {
+		t.Errorf("unexpected discrepancy %+v", d)
+	}
+}
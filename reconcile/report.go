@@ -0,0 +1,84 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/BynxDev/qi"
+)
+
+// Kind is the kind of a discrepancy.
+type Kind string
+
+const (
+	// KindMissing means the gateway has no payment for the request ID.
+	KindMissing Kind = "MISSING"
+	// KindAmountMismatch means the gateway amount differs from the local one.
+	KindAmountMismatch Kind = "AMOUNT_MISMATCH"
+	// KindStatusMismatch means the gateway status differs from the local one.
+	KindStatusMismatch Kind = "STATUS_MISMATCH"
+	// KindUnexpectedCancel means the payment was cancelled on the gateway but
+	// not locally.
+	KindUnexpectedCancel Kind = "UNEXPECTED_CANCEL"
+	// KindMissingCancel means the payment was cancelled locally but not on
+	// the gateway.
+	KindMissingCancel Kind = "MISSING_CANCEL"
+	// KindUnexpectedRefund means the refunded amount differs from the local one.
+	KindUnexpectedRefund Kind = "UNEXPECTED_REFUND"
+	// KindUntracked means the gateway has a payment that has no local record.
+	KindUntracked Kind = "UNTRACKED"
+	// KindError means the gateway state could not be retrieved.
+	KindError Kind = "ERROR"
+)
+
+// Discrepancy describes a difference between a local record and the gateway.
+type Discrepancy struct {
+	Kind           Kind             `json:"kind"`
+	RequestID      string           `json:"requestId"`
+	PaymentID      string           `json:"paymentId,omitempty"`
+	ExpectedAmount float64          `json:"expectedAmount"`
+	ActualAmount   float64          `json:"actualAmount,omitempty"`
+	ExpectedStatus qi.PaymentStatus `json:"expectedStatus,omitempty"`
+	ActualStatus   qi.PaymentStatus `json:"actualStatus,omitempty"`
+	Detail         string           `json:"detail,omitempty"`
+}
+
+// Report is the result of a reconciliation run.
+type Report struct {
+	Checked int `json:"checked"`
+	// GatewayChecked is the number of gateway payments checked by
+	// RunWithGateway.
+	GatewayChecked int           `json:"gatewayChecked,omitempty"`
+	Discrepancies  []Discrepancy `json:"discrepancies"`
+}
+
+// WriteJSONLines writes one JSON object per discrepancy to w.
+func (r *Report) WriteJSONLines(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, d := range r.Discrepancies {
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the discrepancies to w as CSV with a header row.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"kind", "requestId", "paymentId",
+		"expectedAmount", "actualAmount",
+		"expectedStatus", "actualStatus", "detail",
+	})
+	for _, d := range r.Discrepancies {
+		cw.Write([]string{
+			string(d.Kind), d.RequestID, d.PaymentID,
+			qi.FormatAmount(d.ExpectedAmount), qi.FormatAmount(d.ActualAmount),
+			string(d.ExpectedStatus), string(d.ActualStatus), d.Detail,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package qi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BynxDev/qi/phone"
+)
+
+// ErrParticipantNotFound is returned by a ParticipantDirectory for banks that
+// are not Instant Payments participants.
+var ErrParticipantNotFound = errors.New("qi: instant payments participant not found")
+
+// Participant is a bank in the directory of Instant Payments participants.
+type Participant struct {
+	// ID is the participant ID passed as recipientBankId.
+	ID   string
+	Name string
+}
+
+// ParticipantDirectory looks up Instant Payments participants, e.g. from a
+// directory published by the acquirer.
+type ParticipantDirectory interface {
+	// LookupParticipant returns the participant with the given ID or name,
+	// or an error wrapping ErrParticipantNotFound.
+	LookupParticipant(ctx context.Context, bank string) (*Participant, error)
+}
+
+// StaticDirectory is a ParticipantDirectory backed by a fixed list of
+// participants. Names are matched case-insensitively.
+type StaticDirectory []Participant
+
+// LookupParticipant implements ParticipantDirectory.
+func (d StaticDirectory) LookupParticipant(ctx context.Context, bank string) (*Participant, error) {
+	for _, p := range d {
+		if p.ID == bank || strings.EqualFold(p.Name, bank) {
+			p := p
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrParticipantNotFound, bank)
+}
+
+// RefundToPhone returns the extParams of a refund of an Instant payment to the
+// recipient's mobile number at the given bank, which is looked up by ID or
+// name in directory.
+func RefundToPhone(ctx context.Context, directory ParticipantDirectory, phoneNumber, bank string) (*RefundExtParams, error) {
+	n, err := phone.Parse(phoneNumber)
+	if err != nil {
+		return nil, &ValidationError{Field: "extParams.phone", Reason: fmt.Sprintf("%q is not an Iraqi mobile number", phoneNumber)}
+	}
+	participant, err := directory.LookupParticipant(ctx, bank)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up recipient bank: %w", err)
+	}
+	return &RefundExtParams{Phone: n.Digits(), RecipientBankID: participant.ID}, nil
+}
+
+// RefundAsOCT returns the extParams of a refund processed as an Original
+// Credit Transaction to the card of the original payment, ignoring the cutoff
+// timer.
+func RefundAsOCT() *RefundExtParams {
+	return &RefundExtParams{ProcessRefundAsOCT: true}
+}
@@ -0,0 +1,65 @@
+package qi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+var participants = qi.StaticDirectory{
+	{ID: "100000000001", Name: "Rafidain Bank"},
+	{ID: "100000000002", Name: "Rasheed Bank"},
+}
+
+func TestRefundToPhone(t *testing.T) {
+	ext, err := qi.RefundToPhone(context.Background(), participants, "0770 123 4567", "rafidain bank")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ext.Phone != "9647701234567" || ext.RecipientBankID != "100000000001" || ext.ProcessRefundAsOCT {
+		t.Errorf("unexpected extParams %+v", ext)
+	}
+	if err := ext.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	_, err = qi.RefundToPhone(context.Background(), participants, "07701234567", "Unknown Bank")
+	if !errors.Is(err, qi.ErrParticipantNotFound) {
+		t.Errorf("expected ErrParticipantNotFound, got %v", err)
+	}
+
+	_, err = qi.RefundToPhone(context.Background(), participants, "12345", "100000000002")
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "extParams.phone" {
+		t.Errorf("expected extParams.phone validation error, got %v", err)
+	}
+}
+
+func TestRefundExtParamsCombinations(t *testing.T) {
+	tests := []struct {
+		ext   qi.RefundExtParams
+		field string
+	}{
+		{*qi.RefundAsOCT(), ""},
+		{qi.RefundExtParams{Phone: "9647701234567", RecipientBankID: "100000000001"}, ""},
+		{qi.RefundExtParams{Phone: "9647701234567"}, "extParams.recipientBankId"},
+		{qi.RefundExtParams{RecipientBankID: "100000000001"}, "extParams.phone"},
+		{qi.RefundExtParams{Phone: "9647701234567", RecipientBankID: "100000000001", ProcessRefundAsOCT: true}, "extParams.processRefundAsOct"},
+	}
+
+	for _, tt := range tests {
+		err := tt.ext.Validate()
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", tt.ext, err)
+			}
+			continue
+		}
+		var validationErr *qi.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+			t.Errorf("%+v: expected %s validation error, got %v", tt.ext, tt.field, err)
+		}
+	}
+}
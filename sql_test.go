@@ -0,0 +1,325 @@
+package qi_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver is a database/sql driver for the statements issued by the SQL
+// stores. Each data source name is a separate in-memory database.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+var sqlDriver = &fakeDriver{dbs: make(map[string]*fakeDB)}
+
+func init() {
+	sql.Register("qifake", sqlDriver)
+}
+
+// newFakeDB opens a new in-memory database with tables keyed by the given
+// primary key columns.
+func newFakeDB(t *testing.T, primaryKeys map[string][]string) *sql.DB {
+	t.Helper()
+	fdb := &fakeDB{tables: make(map[string]*fakeTable)}
+	for name, key := range primaryKeys {
+		fdb.tables[name] = &fakeTable{key: key}
+	}
+
+	sqlDriver.mu.Lock()
+	sqlDriver.dbs[t.Name()] = fdb
+	sqlDriver.mu.Unlock()
+
+	db, err := sql.Open("qifake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		sqlDriver.mu.Lock()
+		delete(sqlDriver.dbs, t.Name())
+		sqlDriver.mu.Unlock()
+	})
+	return db
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fdb, ok := d.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q", name)
+	}
+	return &fakeConn{db: fdb}, nil
+}
+
+type fakeDB struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	key  []string
+	rows []map[string]driver.Value
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\?|\$\d+`)
+	insertPattern      = regexp.MustCompile(`^INSERT (IGNORE )?INTO (\w+) \(([^)]*)\) VALUES \(([^)]*)\)( ON CONFLICT \([^)]*\) DO NOTHING)?$`)
+	selectPattern      = regexp.MustCompile(`^SELECT (.+?) FROM (\w+)(?: WHERE (.+?))?(?: ORDER BY (\w+))?(?: LIMIT (\d+))?$`)
+	updatePattern      = regexp.MustCompile(`^UPDATE (\w+) SET (.+?) WHERE (.+)$`)
+	deletePattern      = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.+)$`)
+)
+
+// normalize collapses whitespace and numbers the placeholders of a query, so
+// that "?" and "$n" placeholders are both bound by position.
+func normalize(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	n := 0
+	return placeholderPattern.ReplaceAllStringFunc(query, func(string) string {
+		n++
+		return "?" + strconv.Itoa(n)
+	})
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	query = normalize(query)
+	if m := insertPattern.FindStringSubmatch(query); m != nil {
+		table, err := c.db.table(m[2])
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]driver.Value)
+		values := splitList(m[4])
+		for i, column := range splitList(m[3]) {
+			row[column] = value(values[i], args)
+		}
+		if table.find(row) {
+			if m[1] != "" || m[5] != "" {
+				return driver.RowsAffected(0), nil
+			}
+			return nil, errors.New("duplicate primary key")
+		}
+		table.rows = append(table.rows, row)
+		return driver.RowsAffected(1), nil
+	}
+	if m := updatePattern.FindStringSubmatch(query); m != nil {
+		table, err := c.db.table(m[1])
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		for _, row := range table.rows {
+			if !match(row, m[3], args) {
+				continue
+			}
+			for _, assignment := range splitList(m[2]) {
+				column, v, _ := strings.Cut(assignment, " = ")
+				row[column] = value(v, args)
+			}
+			n++
+		}
+		return driver.RowsAffected(n), nil
+	}
+	if m := deletePattern.FindStringSubmatch(query); m != nil {
+		table, err := c.db.table(m[1])
+		if err != nil {
+			return nil, err
+		}
+		kept := table.rows[:0]
+		for _, row := range table.rows {
+			if !match(row, m[2], args) {
+				kept = append(kept, row)
+			}
+		}
+		n := int64(len(table.rows) - len(kept))
+		table.rows = kept
+		return driver.RowsAffected(n), nil
+	}
+	return nil, fmt.Errorf("unsupported statement %q", query)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	query = normalize(query)
+	m := selectPattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported query %q", query)
+	}
+	table, err := c.db.table(m[2])
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []map[string]driver.Value
+	for _, row := range table.rows {
+		if m[3] == "" || match(row, m[3], args) {
+			selected = append(selected, row)
+		}
+	}
+	if m[4] != "" {
+		sort.SliceStable(selected, func(i, j int) bool {
+			return compare(selected[i][m[4]], selected[j][m[4]]) < 0
+		})
+	}
+	if m[5] != "" {
+		limit, _ := strconv.Atoi(m[5])
+		if len(selected) > limit {
+			selected = selected[:limit]
+		}
+	}
+
+	rows := &fakeRows{columns: splitList(m[1])}
+	for _, row := range selected {
+		values := make([]driver.Value, len(rows.columns))
+		for i, column := range rows.columns {
+			values[i] = row[column]
+		}
+		rows.values = append(rows.values, values)
+	}
+	return rows, nil
+}
+
+func (db *fakeDB) table(name string) (*fakeTable, error) {
+	table, ok := db.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("no such table %q", name)
+	}
+	return table, nil
+}
+
+// find returns whether a row with the primary key of row exists.
+func (t *fakeTable) find(row map[string]driver.Value) bool {
+	for _, existing := range t.rows {
+		same := true
+		for _, column := range t.key {
+			if compare(existing[column], row[column]) != 0 {
+				same = false
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	items := strings.Split(list, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// value returns the value of a literal or numbered placeholder.
+func value(expr string, args []driver.NamedValue) driver.Value {
+	if expr == "NULL" {
+		return nil
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(expr, "?"))
+	return args[n-1].Value
+}
+
+// match evaluates a condition made of comparisons joined by AND, and
+// parenthesized comparisons joined by OR.
+func match(row map[string]driver.Value, cond string, args []driver.NamedValue) bool {
+	for _, term := range strings.Split(cond, " AND ") {
+		term = strings.TrimSuffix(strings.TrimPrefix(term, "("), ")")
+		ok := false
+		for _, comparison := range strings.Split(term, " OR ") {
+			if compareTerm(row, comparison, args) {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func compareTerm(row map[string]driver.Value, comparison string, args []driver.NamedValue) bool {
+	fields := strings.Fields(comparison)
+	if len(fields) == 3 && fields[1] == "IS" && fields[2] == "NULL" {
+		return row[fields[0]] == nil
+	}
+	left := row[fields[0]]
+	if left == nil {
+		return false
+	}
+	c := compare(left, value(fields[2], args))
+	switch fields[1] {
+	case "=":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	panic("unsupported operator " + fields[1])
+}
+
+func compare(a, b driver.Value) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case int64:
+		return int(a - b.(int64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("unsupported value %T", a))
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
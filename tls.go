@@ -0,0 +1,180 @@
+package qi
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrPublicKeyNotPinned is matched by errors returned for calls to a server
+// whose verified certificate chain contains none of the public keys pinned with
+// WithPinnedPublicKeys.
+var ErrPublicKeyNotPinned = errors.New("qi: server certificate does not match a pinned public key")
+
+// tlsOptions collects the TLS options, which are applied to the transport
+// once all options have run.
+type tlsOptions struct {
+	config       *tls.Config
+	certificates []tls.Certificate
+	pins         map[[sha256.Size]byte]bool
+}
+
+func (c *Client) tlsOptions() *tlsOptions {
+	if c.tls == nil {
+		c.tls = &tlsOptions{}
+	}
+	return c.tls
+}
+
+// setConfigErr records the first option error, which is returned by every
+// call made with the client.
+func (c *Client) setConfigErr(err error) {
+	if c.configErr == nil {
+		c.configErr = err
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the gateway,
+// e.g. to trust a private certificate authority with RootCAs. The
+// configuration is cloned, and TLS versions below 1.2 are never negotiated.
+//
+// The TLS options replace the transport of the HTTP client with a copy of it,
+// or of http.DefaultTransport, using the configuration. They fail every call
+// if the HTTP client set with WithHTTPClient has a transport other than
+// *http.Transport.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsOptions().config = config.Clone()
+	}
+}
+
+// WithClientCertificate sets the certificate presented to the gateway for
+// mutual TLS, from a PEM encoded certificate chain and private key. If they
+// cannot be parsed, every call fails with the parse error.
+func WithClientCertificate(certPEM, keyPEM []byte) ClientOption {
+	return func(c *Client) {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			c.setConfigErr(fmt.Errorf("failed to load client certificate: %w", err))
+			return
+		}
+		o := c.tlsOptions()
+		o.certificates = append(o.certificates, cert)
+	}
+}
+
+// WithPinnedPublicKeys pins the public keys of the gateway's certificate
+// chain. Connections fail with ErrPublicKeyNotPinned unless a certificate of
+// a verified chain has one of the keys, in addition to the usual certificate
+// verification. If verification is skipped with InsecureSkipVerify, only the
+// gateway's own certificate is checked.
+//
+// Hashes are SHA-256 digests of the DER encoded SubjectPublicKeyInfo, base64
+// or hex encoded, optionally prefixed with "sha256/". If a hash cannot be
+// parsed, every call fails.
+func WithPinnedPublicKeys(hashes ...string) ClientOption {
+	return func(c *Client) {
+		o := c.tlsOptions()
+		if o.pins == nil {
+			o.pins = make(map[[sha256.Size]byte]bool)
+		}
+		for _, h := range hashes {
+			pin, err := parsePin(h)
+			if err != nil {
+				c.setConfigErr(err)
+				return
+			}
+			o.pins[pin] = true
+		}
+	}
+}
+
+// PublicKeyHash returns the base64 encoded SHA-256 digest of the public key of
+// cert, for use with WithPinnedPublicKeys.
+func PublicKeyHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func parsePin(s string) ([sha256.Size]byte, error) {
+	var pin [sha256.Size]byte
+	h := strings.TrimPrefix(strings.TrimSpace(s), "sha256/")
+	b, err := base64.StdEncoding.DecodeString(h)
+	if err != nil || len(b) != sha256.Size {
+		b, err = hex.DecodeString(h)
+	}
+	if err != nil || len(b) != sha256.Size {
+		return pin, fmt.Errorf("qi: invalid SHA-256 public key hash %q", s)
+	}
+	copy(pin[:], b)
+	return pin, nil
+}
+
+// configureTLS replaces the transport of the HTTP client with one using the
+// TLS options.
+func (c *Client) configureTLS() {
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		c.setConfigErr(fmt.Errorf("qi: TLS options require an *http.Transport, got %T", t))
+		return
+	}
+
+	config := c.tls.config
+	if config == nil {
+		config = transport.TLSClientConfig.Clone()
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	config.Certificates = append(config.Certificates, c.tls.certificates...)
+	if len(c.tls.pins) > 0 {
+		config.VerifyConnection = verifyPins(c.tls.pins, config.VerifyConnection)
+	}
+	transport.TLSClientConfig = config
+
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+}
+
+// verifyPins returns a VerifyConnection callback that checks the pins after
+// next.
+func verifyPins(pins map[[sha256.Size]byte]bool, next func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if next != nil {
+			if err := next(cs); err != nil {
+				return err
+			}
+		}
+		// Only the verified chains are trusted: any certificate can be
+		// appended to the ones presented by the server. Without
+		// verification, only the server's own certificate is checked.
+		var certs []*x509.Certificate
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+		if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+			certs = cs.PeerCertificates[:1]
+		}
+		for _, cert := range certs {
+			if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+		return ErrPublicKeyNotPinned
+	}
+}
@@ -0,0 +1,248 @@
+package qi_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BynxDev/qi"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func newTLSServer(t *testing.T, clientCAs *x509.CertPool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"paymentId":"test-payment-id","status":"SUCCESS"}`))
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if clientCAs != nil {
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serverRoots(server *httptest.Server) *tls.Config {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return &tls.Config{RootCAs: roots}
+}
+
+// newClientCertificate returns a self-signed client certificate and key, PEM
+// encoded.
+func newClientCertificate(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-terminal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return cert, certPEM, keyPEM
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	server := newTLSServer(t, nil)
+	ctx := context.Background()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err == nil {
+		t.Fatal("expected error for untrusted server certificate")
+	}
+
+	config := serverRoots(server)
+	config.MinVersion = tls.VersionTLS10
+	client = qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithTLSConfig(config))
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MinVersion != tls.VersionTLS10 {
+		t.Error("expected the configuration to be cloned")
+	}
+}
+
+func TestWithTLSConfigMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	server.StartTLS()
+	defer server.Close()
+
+	config := serverRoots(server)
+	config.MinVersion = tls.VersionTLS10
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithTLSConfig(config))
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error for a server limited to TLS 1.1")
+	}
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	cert, certPEM, keyPEM := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	server := newTLSServer(t, clientCAs)
+	ctx := context.Background()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL), qi.WithTLSConfig(serverRoots(server)))
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err == nil {
+		t.Fatal("expected error without client certificate")
+	}
+
+	// The certificate option also applies when set before the configuration.
+	client = qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithClientCertificate(certPEM, keyPEM),
+		qi.WithTLSConfig(serverRoots(server)),
+	)
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithClientCertificateInvalid(t *testing.T) {
+	client := qi.NewClient("test-terminal", qi.WithClientCertificate([]byte("not a certificate"), nil))
+	_, err := client.GetPaymentStatus(context.Background(), "test-payment-id")
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to load client certificate") {
+		t.Errorf("expected client certificate error, got %v", err)
+	}
+}
+
+func TestWithPinnedPublicKeys(t *testing.T) {
+	server := newTLSServer(t, nil)
+	ctx := context.Background()
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+
+	tests := []struct {
+		name string
+		pins []string
+		err  error
+	}{
+		{"base64", []string{qi.PublicKeyHash(server.Certificate())}, nil},
+		{"prefixed", []string{"sha256/" + qi.PublicKeyHash(server.Certificate())}, nil},
+		{"hex", []string{hex.EncodeToString(sum[:])}, nil},
+		{"backup pin", []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", qi.PublicKeyHash(server.Certificate())}, nil},
+		{"mismatch", []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}, qi.ErrPublicKeyNotPinned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := qi.NewClient("test-terminal",
+				qi.WithBaseURL(server.URL),
+				qi.WithTLSConfig(serverRoots(server)),
+				qi.WithPinnedPublicKeys(tt.pins...),
+			)
+			_, err := client.GetPaymentStatus(ctx, "test-payment-id")
+			if tt.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestWithPinnedPublicKeysUnverifiedCertificate(t *testing.T) {
+	server := newTLSServer(t, nil)
+	ctx := context.Background()
+
+	// The server presents a certificate with the pinned key that is not
+	// part of its chain.
+	pinned, _, _ := newClientCertificate(t)
+	server.TLS.Certificates[0].Certificate = append(server.TLS.Certificates[0].Certificate, pinned.Raw)
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithTLSConfig(serverRoots(server)),
+		qi.WithPinnedPublicKeys(qi.PublicKeyHash(pinned)),
+	)
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); !errors.Is(err, qi.ErrPublicKeyNotPinned) {
+		t.Fatalf("expected %v, got %v", qi.ErrPublicKeyNotPinned, err)
+	}
+
+	client = qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+		qi.WithPinnedPublicKeys(qi.PublicKeyHash(pinned)),
+	)
+	if _, err := client.GetPaymentStatus(ctx, "test-payment-id"); !errors.Is(err, qi.ErrPublicKeyNotPinned) {
+		t.Fatalf("expected %v without verification, got %v", qi.ErrPublicKeyNotPinned, err)
+	}
+}
+
+func TestWithPinnedPublicKeysInvalid(t *testing.T) {
+	client := qi.NewClient("test-terminal", qi.WithPinnedPublicKeys("not-a-hash"))
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error for invalid pin")
+	}
+}
+
+func TestTLSOptionsKeepHTTPClient(t *testing.T) {
+	server := newTLSServer(t, nil)
+
+	httpClient := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{}}
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithHTTPClient(httpClient),
+		qi.WithTLSConfig(serverRoots(server)),
+	)
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config := httpClient.Transport.(*http.Transport).TLSClientConfig; config != nil && config.RootCAs != nil {
+		t.Error("expected the HTTP client not to be modified")
+	}
+
+	custom := &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+	client = qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithHTTPClient(custom),
+		qi.WithTLSConfig(serverRoots(server)),
+	)
+	if _, err := client.GetPaymentStatus(context.Background(), "test-payment-id"); err == nil {
+		t.Fatal("expected error for a transport that cannot be configured")
+	}
+}
@@ -0,0 +1,206 @@
+package qi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// TransferStatus represents the status of a transfer.
+type TransferStatus string
+
+const (
+	TransferStatusCreated    TransferStatus = "CREATED"
+	TransferStatusProcessing TransferStatus = "PROCESSING"
+	TransferStatusSuccess    TransferStatus = "SUCCESS"
+	TransferStatusFailed     TransferStatus = "FAILED"
+	TransferStatusCanceled   TransferStatus = "CANCELED"
+)
+
+// IsKnown returns true if s is a status known to this version of the client.
+func (s TransferStatus) IsKnown() bool {
+	switch s {
+	case TransferStatusCreated, TransferStatusProcessing, TransferStatusSuccess,
+		TransferStatusFailed, TransferStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// IsTerminal returns true if the transfer will not change status anymore.
+func (s TransferStatus) IsTerminal() bool {
+	return s == TransferStatusSuccess || s == TransferStatusFailed || s == TransferStatusCanceled
+}
+
+// CreateTransferRequest represents a request to transfer funds to a
+// recipient, e.g. a payout to a seller.
+type CreateTransferRequest struct {
+	RequestID       string            `json:"requestId"`
+	Amount          float64           `json:"amount"`
+	Currency        Currency          `json:"currency,omitempty"`
+	Message         string            `json:"message,omitempty"`
+	NotificationURL string            `json:"notificationUrl,omitempty"`
+	RecipientInfo   *CustomerInfo     `json:"recipientInfo,omitempty"`
+	AdditionalInfo  map[string]string `json:"additionalInfo,omitempty"`
+}
+
+// CancelTransferRequest represents a request to cancel a transfer.
+type CancelTransferRequest struct {
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Transfer represents transfer details returned from the API.
+type Transfer struct {
+	RequestID      string            `json:"requestId"`
+	TransferID     string            `json:"transferId"`
+	Status         TransferStatus    `json:"status"`
+	Canceled       bool              `json:"canceled,omitempty"`
+	Amount         float64           `json:"amount"`
+	Currency       Currency          `json:"currency"`
+	CreationDate   Time              `json:"creationDate"`
+	Details        *PaymentDetails   `json:"details,omitempty"`
+	AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
+
+	rawPayload
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *Transfer) UnmarshalJSON(data []byte) error {
+	type transfer Transfer
+	if err := json.Unmarshal(data, (*transfer)(t)); err != nil {
+		return err
+	}
+	t.capture(data, reflect.TypeOf(*t))
+	return nil
+}
+
+// DecodeAdditionalInfo decodes the additionalInfo of the transfer into v. See
+// the DecodeAdditionalInfo function.
+func (t *Transfer) DecodeAdditionalInfo(v interface{}) error {
+	return DecodeAdditionalInfo(t.AdditionalInfo, v)
+}
+
+// Validate checks the request for errors that the API would otherwise reject.
+func (r *CreateTransferRequest) Validate() error {
+	if r.RequestID == "" {
+		return &ValidationError{Field: "requestId", Reason: "required"}
+	}
+	if r.Amount <= 0 {
+		return &ValidationError{Field: "amount", Reason: fmt.Sprintf("must be positive, got %v", r.Amount)}
+	}
+	if r.Currency != "" && !r.Currency.IsKnown() {
+		return &ValidationError{Field: "currency", Reason: fmt.Sprintf("unknown ISO 4217 code %q", r.Currency)}
+	}
+	if len(r.AdditionalInfo) > MaxAdditionalInfo {
+		return &ValidationError{
+			Field:  "additionalInfo",
+			Reason: fmt.Sprintf("%d properties exceed the limit of %d", len(r.AdditionalInfo), MaxAdditionalInfo),
+		}
+	}
+	if r.RecipientInfo != nil {
+		if err := r.RecipientInfo.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransferPaths are the endpoint paths of the transfer operations, relative
+// to the base URL. {id} is replaced by the path-escaped transfer ID or
+// request ID.
+type TransferPaths struct {
+	Create          string
+	Status          string
+	StatusByRequest string
+	Cancel          string
+	CancelByRequest string
+}
+
+// DefaultTransferPaths are the transfer endpoint paths, following the
+// conventions of the payment endpoints. The transfer endpoints are not part
+// of the published API specification, so WithTransferPaths can adapt them to
+// the paths provided by the acquirer.
+var DefaultTransferPaths = TransferPaths{
+	Create:          "/transfer",
+	Status:          "/transfer/{id}/status",
+	StatusByRequest: "/transfer/status/by/request/{id}",
+	Cancel:          "/transfer/{id}/cancel",
+	CancelByRequest: "/transfer/cancel/by/request/{id}",
+}
+
+// WithTransferPaths overrides the endpoint paths of the transfer operations.
+// Empty paths keep their default.
+func WithTransferPaths(paths TransferPaths) ClientOption {
+	return func(c *Client) {
+		set := func(dst *string, src string) {
+			if src != "" {
+				*dst = src
+			}
+		}
+		set(&c.transferPaths.Create, paths.Create)
+		set(&c.transferPaths.Status, paths.Status)
+		set(&c.transferPaths.StatusByRequest, paths.StatusByRequest)
+		set(&c.transferPaths.Cancel, paths.Cancel)
+		set(&c.transferPaths.CancelByRequest, paths.CancelByRequest)
+	}
+}
+
+func transferPath(template, id string) string {
+	return strings.ReplaceAll(template, "{id}", escapeID(id))
+}
+
+// CreateTransfer creates a transfer. The gateway rejects a reused requestId
+// with ErrorCodeTransferAlreadyExists.
+func (c *Client) CreateTransfer(ctx context.Context, req *CreateTransferRequest, opts ...CallOption) (*Transfer, error) {
+	if req != nil {
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var transfer Transfer
+	if err := c.doRequest(ctx, OperationCreateTransfer, http.MethodPost, c.transferPaths.Create, req, &transfer, opts); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetTransferStatus retrieves the status of a transfer by transfer ID.
+func (c *Client) GetTransferStatus(ctx context.Context, transferID string, opts ...CallOption) (*Transfer, error) {
+	var transfer Transfer
+	if err := c.doRequest(ctx, OperationGetTransferStatus, http.MethodGet, transferPath(c.transferPaths.Status, transferID), nil, &transfer, opts); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetTransferStatusByRequest retrieves the status of a transfer by request ID.
+func (c *Client) GetTransferStatusByRequest(ctx context.Context, requestID string, opts ...CallOption) (*Transfer, error) {
+	var transfer Transfer
+	if err := c.doRequest(ctx, OperationGetTransferStatusByRequest, http.MethodGet, transferPath(c.transferPaths.StatusByRequest, requestID), nil, &transfer, opts); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// CancelTransfer cancels a transfer by transfer ID. Transfers that are
+// already processed fail with ErrorCodeCanNotCancelTransfer.
+func (c *Client) CancelTransfer(ctx context.Context, transferID string, req *CancelTransferRequest, opts ...CallOption) (*Transfer, error) {
+	var transfer Transfer
+	if err := c.doRequest(ctx, OperationCancelTransfer, http.MethodPost, transferPath(c.transferPaths.Cancel, transferID), req, &transfer, opts); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// CancelTransferByRequest cancels a transfer by request ID.
+func (c *Client) CancelTransferByRequest(ctx context.Context, requestID string, req *CancelTransferRequest, opts ...CallOption) (*Transfer, error) {
+	var transfer Transfer
+	if err := c.doRequest(ctx, OperationCancelTransferByRequest, http.MethodPost, transferPath(c.transferPaths.CancelByRequest, requestID), req, &transfer, opts); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
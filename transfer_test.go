@@ -0,0 +1,132 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+	"github.com/BynxDev/qi/qimock"
+)
+
+func TestCreateTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/transfer" {
+			t.Errorf("expected POST /transfer, got %s %s", r.Method, r.URL.Path)
+		}
+
+		var req qi.CreateTransferRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.Transfer{
+			RequestID:  req.RequestID,
+			TransferID: "test-transfer-id",
+			Status:     qi.TransferStatusCreated,
+			Amount:     req.Amount,
+			Currency:   req.Currency,
+		})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+
+	transfer, err := client.CreateTransfer(context.Background(), &qi.CreateTransferRequest{
+		RequestID:     "transfer-request-id",
+		Amount:        25000,
+		Currency:      qi.CurrencyIQD,
+		RecipientInfo: &qi.CustomerInfo{FirstName: "John", Phone: "009647701234567"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transfer.TransferID != "test-transfer-id" || transfer.Status != qi.TransferStatusCreated {
+		t.Errorf("unexpected transfer %+v", transfer)
+	}
+}
+
+func TestCreateTransferValidation(t *testing.T) {
+	client := qi.NewClient("test-terminal", qi.WithBaseURL("http://qi.invalid"))
+
+	_, err := client.CreateTransfer(context.Background(), &qi.CreateTransferRequest{RequestID: "transfer-request-id"})
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "amount" {
+		t.Errorf("expected amount validation error, got %v", err)
+	}
+}
+
+func TestTransferPaths(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qi.Transfer{TransferID: "test-transfer-id", Status: qi.TransferStatusCanceled})
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal",
+		qi.WithBaseURL(server.URL),
+		qi.WithTransferPaths(qi.TransferPaths{Status: "/payout/{id}"}),
+	)
+
+	ctx := context.Background()
+	if _, err := client.GetTransferStatus(ctx, "test-transfer-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTransferStatusByRequest(ctx, "test-request-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CancelTransferByRequest(ctx, "test-request-id", &qi.CancelTransferRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CancelTransfer(ctx, "a/b?c", &qi.CancelTransferRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTransferStatusByRequest(ctx, ".."); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"GET /payout/test-transfer-id",
+		"GET /transfer/status/by/request/test-request-id",
+		"POST /transfer/cancel/by/request/test-request-id",
+		"POST /transfer/a%2Fb%3Fc/cancel",
+		"GET /transfer/status/by/request/%2E%2E",
+	}
+	for i := range want {
+		if i >= len(paths) || paths[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, paths)
+			break
+		}
+	}
+}
+
+func TestMockTransfers(t *testing.T) {
+	mock := &qimock.Gateway{
+		CancelTransferFunc: func(ctx context.Context, transferID string, req *qi.CancelTransferRequest) (*qi.Transfer, error) {
+			return nil, &qi.APIError{StatusCode: 400, Err: &qi.Error{Error: qi.ErrorDetails{Code: qi.ErrorCodeCanNotCancelTransfer}}}
+		},
+	}
+
+	var m metrics
+	gw := qi.WrapGateway(mock, qi.MetricsMiddleware(&m))
+
+	_, err := gw.CancelTransfer(context.Background(), "test-transfer-id", &qi.CancelTransferRequest{})
+	var apiErr *qi.APIError
+	if !errors.As(err, &apiErr) || apiErr.Err.Error.Code != qi.ErrorCodeCanNotCancelTransfer {
+		t.Errorf("expected CAN_NOT_CANCEL_TRANSFER, got %v", err)
+	}
+	if calls := mock.CallsTo(qi.OperationCancelTransfer); len(calls) != 1 || calls[0].Args[0] != "test-transfer-id" {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+	if len(m) != 1 || m[0].operation != qi.OperationCancelTransfer {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+
+	if _, err := gw.GetTransferStatus(context.Background(), "test-transfer-id"); !errors.Is(err, qimock.ErrNotImplemented) {
+		t.Errorf("expected ErrNotImplemented, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package qi
+
+import (
+	"fmt"
+
+	"github.com/BynxDev/qi/phone"
+)
+
+// ValidationError is returned when a request fails client-side validation
+// before it is sent to the API.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Validate checks the request for errors that the API would otherwise reject.
+func (r *CreatePaymentRequest) Validate() error {
+	if r.Currency != "" && !r.Currency.IsKnown() {
+		return &ValidationError{Field: "currency", Reason: fmt.Sprintf("unknown ISO 4217 code %q", r.Currency)}
+	}
+	if len(r.AdditionalInfo) > MaxAdditionalInfo {
+		return &ValidationError{
+			Field:  "additionalInfo",
+			Reason: fmt.Sprintf("%d properties exceed the limit of %d", len(r.AdditionalInfo), MaxAdditionalInfo),
+		}
+	}
+	if r.CustomerInfo != nil {
+		if err := r.CustomerInfo.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.ItemsInfo != nil {
+		if err := r.ItemsInfo.Validate(r.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the customer details for errors that the API would
+// otherwise reject.
+func (c *CustomerInfo) Validate() error {
+	if c.Phone != "" && !phone.Valid(c.Phone) {
+		return &ValidationError{Field: "customerInfo.phone", Reason: fmt.Sprintf("%q is not an Iraqi mobile number", c.Phone)}
+	}
+	countries := []struct {
+		field   string
+		country Country
+	}{
+		{"countryCode", c.CountryCode},
+		{"identificationCountryCode", c.IdentificationCountryCode},
+		{"nationality", c.Nationality},
+		{"countryOfBirth", c.CountryOfBirth},
+	}
+	for _, f := range countries {
+		if f.country != "" && !f.country.IsKnown() {
+			return &ValidationError{Field: "customerInfo." + f.field, Reason: fmt.Sprintf("unknown ISO 3166-1 code %q", f.country)}
+		}
+	}
+	if len(c.ProvinceCode) > MaxProvinceLength {
+		return &ValidationError{
+			Field:  "customerInfo.provinceCode",
+			Reason: fmt.Sprintf("%q exceeds %d characters", c.ProvinceCode, MaxProvinceLength),
+		}
+	}
+	if c.IdentificationType != "" && !c.IdentificationType.IsKnown() {
+		return &ValidationError{Field: "customerInfo.identificationType", Reason: fmt.Sprintf("unknown identification type %q", c.IdentificationType)}
+	}
+	if c.FundSource != "" && !c.FundSource.IsKnown() {
+		return &ValidationError{Field: "customerInfo.fundSource", Reason: fmt.Sprintf("unknown fund source %q", c.FundSource)}
+	}
+	return nil
+}
+
+// Validate checks the request for errors that the API would otherwise reject.
+func (r *CreateRefundRequest) Validate() error {
+	if r.ExtParams != nil {
+		if err := r.ExtParams.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the refund parameters for errors that the API would
+// otherwise reject. A refund either goes to the phone of an Instant payment
+// recipient, which needs both the phone and the recipient bank, or is
+// processed as an OCT.
+func (p *RefundExtParams) Validate() error {
+	if p.Phone != "" && !phone.Valid(p.Phone) {
+		return &ValidationError{Field: "extParams.phone", Reason: fmt.Sprintf("%q is not an Iraqi mobile number", p.Phone)}
+	}
+	instant := p.Phone != "" || p.RecipientBankID != ""
+	switch {
+	case p.ProcessRefundAsOCT && instant:
+		return &ValidationError{Field: "extParams.processRefundAsOct", Reason: "cannot be combined with an Instant payment recipient"}
+	case p.Phone != "" && p.RecipientBankID == "":
+		return &ValidationError{Field: "extParams.recipientBankId", Reason: "required with a recipient phone"}
+	case p.RecipientBankID != "" && p.Phone == "":
+		return &ValidationError{Field: "extParams.phone", Reason: "required with a recipient bank"}
+	}
+	return nil
+}
+
+// normalized returns a copy of the request with the customer phone in the
+// 00964 form the API expects. The caller's request is not modified.
+func (r *CreatePaymentRequest) normalized() *CreatePaymentRequest {
+	if r.CustomerInfo == nil || r.CustomerInfo.Phone == "" {
+		return r
+	}
+	n, err := phone.Parse(r.CustomerInfo.Phone)
+	if err != nil {
+		return r
+	}
+	req := *r
+	info := *r.CustomerInfo
+	info.Phone = n.International()
+	req.CustomerInfo = &info
+	return &req
+}
+
+// normalized returns a copy of the request with the recipient phone in the
+// 964 form the API expects. The caller's request is not modified.
+func (r *CreateRefundRequest) normalized() *CreateRefundRequest {
+	if r.ExtParams == nil || r.ExtParams.Phone == "" {
+		return r
+	}
+	n, err := phone.Parse(r.ExtParams.Phone)
+	if err != nil {
+		return r
+	}
+	req := *r
+	ext := *r.ExtParams
+	ext.Phone = n.Digits()
+	req.ExtParams = &ext
+	return &req
+}
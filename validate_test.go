@@ -0,0 +1,86 @@
+package qi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func TestValidatePhone(t *testing.T) {
+	req := &qi.CreatePaymentRequest{
+		RequestID:    "r-1",
+		Amount:       100,
+		CustomerInfo: &qi.CustomerInfo{Phone: "009647701234567"},
+	}
+	if err := req.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	req.CustomerInfo.Phone = "12345"
+	var validationErr *qi.ValidationError
+	if err := req.Validate(); !errors.As(err, &validationErr) || validationErr.Field != "customerInfo.phone" {
+		t.Errorf("expected customerInfo.phone validation error, got %v", err)
+	}
+}
+
+func TestRefundValidatesPhone(t *testing.T) {
+	client := qi.NewClient("test-terminal", qi.WithBaseURL("http://qi.invalid"))
+
+	_, err := client.RefundPayment(context.Background(), "test-payment-id", &qi.CreateRefundRequest{
+		ExtParams: &qi.RefundExtParams{Phone: "+4477009001"},
+	})
+	var validationErr *qi.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "extParams.phone" {
+		t.Errorf("expected extParams.phone validation error, got %v", err)
+	}
+}
+
+func TestPhoneNormalized(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	client := qi.NewClient("test-terminal", qi.WithBaseURL(server.URL))
+	payment := &qi.CreatePaymentRequest{
+		RequestID:    "r-1",
+		Amount:       100,
+		CustomerInfo: &qi.CustomerInfo{Phone: "0770 123 4567"},
+	}
+	if _, err := client.CreatePayment(context.Background(), payment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refund := &qi.CreateRefundRequest{
+		RequestID: "f-1",
+		ExtParams: &qi.RefundExtParams{Phone: "+964 770 123 4567", RecipientBankID: "100000000001"},
+	}
+	if _, err := client.RefundPayment(context.Background(), "p-1", refund); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(bodies))
+	}
+	if info, _ := bodies[0]["customerInfo"].(map[string]interface{}); info["phone"] != "009647701234567" {
+		t.Errorf("expected customerInfo.phone in 00964 form, got %v", bodies[0]["customerInfo"])
+	}
+	if ext, _ := bodies[1]["extParams"].(map[string]interface{}); ext["phone"] != "9647701234567" {
+		t.Errorf("expected extParams.phone in 964 form, got %v", bodies[1]["extParams"])
+	}
+	if payment.CustomerInfo.Phone != "0770 123 4567" || refund.ExtParams.Phone != "+964 770 123 4567" {
+		t.Error("expected the caller's requests not to be modified")
+	}
+}
@@ -0,0 +1,44 @@
+package qi
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWaitInterval is the default interval between status polls.
+const DefaultWaitInterval = 5 * time.Second
+
+// WaitForPayment polls the payment status by payment ID until the payment
+// reaches a terminal status or ctx is done.
+func (c *Client) WaitForPayment(ctx context.Context, paymentID string, interval time.Duration) (*PaymentStatusResponse, error) {
+	return c.waitFor(ctx, interval, func() (*PaymentStatusResponse, error) {
+		return c.GetPaymentStatus(ctx, paymentID)
+	})
+}
+
+// WaitForPaymentByRequest polls the payment status by request ID until the
+// payment reaches a terminal status or ctx is done.
+func (c *Client) WaitForPaymentByRequest(ctx context.Context, requestID string, interval time.Duration) (*PaymentStatusResponse, error) {
+	return c.waitFor(ctx, interval, func() (*PaymentStatusResponse, error) {
+		return c.GetPaymentStatusByRequest(ctx, requestID)
+	})
+}
+
+func (c *Client) waitFor(ctx context.Context, interval time.Duration, get func() (*PaymentStatusResponse, error)) (*PaymentStatusResponse, error) {
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+
+	for {
+		status, err := get()
+		if err != nil {
+			return nil, err
+		}
+		if status.Status.IsTerminal() {
+			return status, nil
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return status, err
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package qi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxNotificationSize is the maximum size of a notification body accepted by
+// NotificationHandler.
+const MaxNotificationSize = 1 << 20
+
+// ErrInvalidSignature is returned when a notification signature does not
+// match the gateway's public key.
+var ErrInvalidSignature = errors.New("qi: invalid notification signature")
+
+// gatewayZone is the time zone of the date and time values used by the API.
+var gatewayZone = time.FixedZone("GMT+3", 3*60*60)
+
+// ParsePublicKey parses a PEM encoded RSA public key, as provided by the
+// acquirer for verifying notification signatures.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("qi: no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("qi: public key is %T, not RSA", key)
+	}
+	return rsaKey, nil
+}
+
+// notificationFields contains the raw values of the signed notification fields.
+type notificationFields struct {
+	PaymentID    *string      `json:"paymentId"`
+	Amount       *json.Number `json:"amount"`
+	Currency     *string      `json:"currency"`
+	CreationDate *string      `json:"creationDate"`
+	Status       *string      `json:"status"`
+}
+
+// NotificationSignatureData returns the string signed by the gateway for a
+// notification body: paymentId, amount, currency, creationDate and status
+// joined by "|", with "-" in place of missing values.
+func NotificationSignatureData(body []byte) (string, error) {
+	var f notificationFields
+	if err := json.Unmarshal(body, &f); err != nil {
+		return "", fmt.Errorf("failed to unmarshal notification: %w", err)
+	}
+
+	values := []string{"-", "-", "-", "-", "-"}
+	if f.PaymentID != nil {
+		values[0] = *f.PaymentID
+	}
+	if f.Amount != nil {
+		amount, err := f.Amount.Float64()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse amount: %w", err)
+		}
+		values[1] = FormatAmount(amount)
+	}
+	if f.Currency != nil {
+		values[2] = *f.Currency
+	}
+	if f.CreationDate != nil {
+		values[3] = formatGatewayTime(*f.CreationDate)
+	}
+	if f.Status != nil {
+		values[4] = *f.Status
+	}
+	return strings.Join(values, "|"), nil
+}
+
+// formatGatewayTime formats a date and time as yyyy-MM-ddTHH:mm:ss in GMT+3.
+// Values that are already in that format are returned unchanged.
+func formatGatewayTime(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.In(gatewayZone).Format("2006-01-02T15:04:05")
+}
+
+// VerifyNotification verifies the base64 encoded X-Signature of a
+// notification body using the gateway's public key.
+func VerifyNotification(publicKey *rsa.PublicKey, body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	data, err := NotificationSignatureData(body)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(data))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], sig); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// NotificationFunc is called for each verified notification. Returning an
+// error makes the handler respond with 500, so the gateway repeats the
+// notification later.
+type NotificationFunc func(ctx context.Context, payment *Payment) error
+
+// NotificationHandler is an http.Handler for the notifications the gateway
+// sends to notificationUrl.
+type NotificationHandler struct {
+	publicKey *rsa.PublicKey
+	fn        NotificationFunc
+	dedup     DedupStore
+	inbox     Inbox
+}
+
+// NotificationOption is a function that configures a NotificationHandler.
+type NotificationOption func(*NotificationHandler)
+
+// WithDedupStore sets the store used to skip repeated and stale
+// notifications. Skipped notifications are acknowledged with 200 but not
+// passed to the NotificationFunc.
+func WithDedupStore(store DedupStore) NotificationOption {
+	return func(h *NotificationHandler) {
+		h.dedup = store
+	}
+}
+
+// NewNotificationHandler creates a new NotificationHandler that verifies
+// notifications with publicKey and passes them to fn.
+func NewNotificationHandler(publicKey *rsa.PublicKey, fn NotificationFunc, opts ...NotificationOption) *NotificationHandler {
+	h := &NotificationHandler{
+		publicKey: publicKey,
+		fn:        fn,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxNotificationSize))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.verify(body, r.Header.Get("X-Signature"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidSignature) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if h.inbox != nil {
+		if err := h.enqueue(r.Context(), body, r.Header); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.dispatch(r.Context(), payment); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify checks the signature of a notification body and decodes it.
+func (h *NotificationHandler) verify(body []byte, signature string) (*Payment, error) {
+	if err := VerifyNotification(h.publicKey, body, signature); err != nil {
+		return nil, err
+	}
+
+	var payment Payment
+	if err := json.Unmarshal(body, &payment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification: %w", err)
+	}
+	return &payment, nil
+}
+
+// dispatch passes a verified notification to the NotificationFunc unless the
+// dedup store has already seen it or a later status of the same payment.
+//
+// The status is recorded before the other statuses are read, so that of
+// concurrent deliveries of a status only the one that recorded it is
+// dispatched. Conflicting terminal statuses, such as SUCCESS and FAILED, are
+// both dispatched.
+func (h *NotificationHandler) dispatch(ctx context.Context, payment *Payment) error {
+	if h.dedup == nil {
+		return h.fn(ctx, payment)
+	}
+
+	added, err := h.dedup.Add(ctx, payment.PaymentID, payment.Status)
+	if err != nil || !added {
+		return err
+	}
+
+	seen, err := h.dedup.Statuses(ctx, payment.PaymentID)
+	if err == nil {
+		for _, status := range seen {
+			if isStale(status, payment.Status) {
+				return nil
+			}
+		}
+		err = h.fn(ctx, payment)
+	}
+	if err != nil {
+		if removeErr := h.dedup.Remove(ctx, payment.PaymentID, payment.Status); removeErr != nil {
+			return fmt.Errorf("%w (failed to remove dedup entry: %v)", err, removeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// isStale returns true if a notification with status next was overtaken by an
+// already recorded notification with status seen. Statuses unknown to the
+// client are never stale.
+func isStale(seen, next PaymentStatus) bool {
+	if seen == next || !seen.IsKnown() || !next.IsKnown() {
+		return false
+	}
+	return next.Precedes(seen)
+}
@@ -0,0 +1,159 @@
+package qi_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BynxDev/qi"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func signNotification(t *testing.T, key *rsa.PrivateKey, body []byte) string {
+	t.Helper()
+	data, err := qi.NotificationSignatureData(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hash := sha256.Sum256([]byte(data))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func notificationBody(paymentID string, status qi.PaymentStatus) []byte {
+	return []byte(fmt.Sprintf(`{"paymentId":%q,"requestId":"r","amount":256.8,"currency":"IQD","creationDate":"2024-08-04T15:34:33","status":%q}`,
+		paymentID, status))
+}
+
+func TestNotificationSignatureData(t *testing.T) {
+	data, err := qi.NotificationSignatureData([]byte(`{"paymentId":"p1","amount":256.8,"creationDate":"2024-08-04T12:34:33Z","status":"SUCCESS"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "p1|256.80|-|2024-08-04T15:34:33|SUCCESS"; data != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pub, err := qi.ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !pub.Equal(&key.PublicKey) {
+		t.Error("parsed key does not match")
+	}
+}
+
+func TestNotificationHandler(t *testing.T) {
+	key := newTestKey(t)
+
+	var dispatched []qi.PaymentStatus
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			dispatched = append(dispatched, payment.Status)
+			return nil
+		},
+		qi.WithDedupStore(qi.NewMemoryDedupStore(0)),
+	)
+
+	send := func(body []byte, signature string) int {
+		r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+		r.Header.Set("X-Signature", signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	started := notificationBody("p1", qi.PaymentStatusStarted)
+	success := notificationBody("p1", qi.PaymentStatusSuccess)
+
+	if code := send(success, signNotification(t, key, started)); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for wrong signature, got %d", code)
+	}
+
+	for _, body := range [][]byte{started, success, success, started} {
+		if code := send(body, signNotification(t, key, body)); code != http.StatusOK {
+			t.Errorf("expected 200, got %d", code)
+		}
+	}
+
+	want := []qi.PaymentStatus{qi.PaymentStatusStarted, qi.PaymentStatusSuccess}
+	if fmt.Sprint(dispatched) != fmt.Sprint(want) {
+		t.Errorf("expected %v dispatched, got %v", want, dispatched)
+	}
+}
+
+func TestNotificationHandlerRetry(t *testing.T) {
+	key := newTestKey(t)
+
+	calls := 0
+	handler := qi.NewNotificationHandler(&key.PublicKey,
+		func(ctx context.Context, payment *qi.Payment) error {
+			calls++
+			if calls == 1 {
+				return fmt.Errorf("database unavailable")
+			}
+			return nil
+		},
+		qi.WithDedupStore(qi.NewMemoryDedupStore(0)),
+	)
+
+	body := notificationBody("p1", qi.PaymentStatusSuccess)
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+		r.Header.Set("X-Signature", signNotification(t, key, body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("expected %d, got %d", want, w.Code)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected failed notification to be dispatched again, got %d calls", calls)
+	}
+}
+
+func TestMemoryDedupStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := qi.NewMemoryDedupStore(2)
+
+	store.Add(ctx, "p1", qi.PaymentStatusSuccess)
+	store.Add(ctx, "p2", qi.PaymentStatusSuccess)
+	store.Add(ctx, "p3", qi.PaymentStatusSuccess)
+
+	if statuses, _ := store.Statuses(ctx, "p1"); len(statuses) != 0 {
+		t.Errorf("expected p1 to be evicted, got %v", statuses)
+	}
+	if added, _ := store.Add(ctx, "p3", qi.PaymentStatusSuccess); added {
+		t.Error("expected duplicate to be rejected")
+	}
+}